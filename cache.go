@@ -0,0 +1,82 @@
+// Package main provides the on-disk cache of short-lived credentials.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// expiryMargin is subtracted from the expiry of short-lived credentials, so that they are never handed out, whether
+// from the cache or to the kubelet, moments before they expire.
+const expiryMargin = 30 * time.Second
+
+// cacheable is implemented by values that can be cached until they expire.
+type cacheable interface {
+	// cacheExpiry returns when the value expires, or the zero time if it must not be cached.
+	cacheExpiry() time.Time
+}
+
+// cachePath returns the file for the key in the named cache, or an empty string if no cache directory is available.
+// The file name is derived from every part of the key, so that changing any of them invalidates the cache.
+func cachePath(name string, key ...string) string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join(key, "\x00")))
+	return filepath.Join(cacheDir, "docker-credential-env", name, hex.EncodeToString(sum[:])+".json")
+}
+
+// readCache loads a cached value, returning false if it is missing, unreadable or about to expire.
+func readCache[T cacheable](path string) (value T, ok bool) {
+	if path == "" {
+		return value, false
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- path is derived from a hash
+	if err != nil {
+		return value, false
+	}
+	var cached T
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return value, false
+	}
+	if time.Now().Add(expiryMargin).After(cached.cacheExpiry()) {
+		return value, false
+	}
+	return cached, true
+}
+
+// writeCache stores a value in the cache, unless it has no expiry. Failures are ignored, as the cache is only an
+// optimisation.
+func writeCache[T cacheable](path string, value T) {
+	if path == "" || value.cacheExpiry().IsZero() {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	// Write via a temporary file so that a concurrent read never sees a partial value
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".cache-*")
+	if err != nil {
+		return
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	_ = os.Rename(tmp.Name(), path)
+}
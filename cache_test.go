@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    oauth2Token
+		expected bool
+	}{
+		{
+			name:     "Valid",
+			input:    oauth2Token{AccessToken: "token", Expiry: time.Now().Add(time.Hour)},
+			expected: true,
+		},
+		{
+			name:     "Within the margin",
+			input:    oauth2Token{AccessToken: "token", Expiry: time.Now().Add(expiryMargin - time.Second)},
+			expected: false,
+		},
+		{
+			name:     "Without expiry",
+			input:    oauth2Token{AccessToken: "token"},
+			expected: false,
+		},
+		{
+			name:     "Empty",
+			input:    oauth2Token{Expiry: time.Now().Add(time.Hour)},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+			path := cachePath("test", tt.name)
+			writeCache(path, tt.input)
+			actual, ok := readCache[oauth2Token](path)
+			if ok != tt.expected || (ok && (actual.AccessToken != tt.input.AccessToken || !actual.Expiry.Equal(tt.input.Expiry))) {
+				t.Errorf("readCache(%v) actual = (%v, %v), expected (%v, %v)", tt.name, actual, ok, tt.input, tt.expected)
+			}
+
+			entries, err := os.ReadDir(filepath.Dir(path))
+			if err != nil && !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if entry.Name() != filepath.Base(path) {
+					t.Errorf("unexpected file %q left in the cache", entry.Name())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,494 @@
+// Package main provides the declarative credential rules configuration file.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// credentialConfig is the declarative configuration file: an ordered list of rules,
+// the first matching rule whose provider finds credentials winning.
+type credentialConfig struct {
+	Rules []credentialRule `yaml:"rules"`
+}
+
+// credentialRule selects a provider for hostnames matching either a glob or a regular expression.
+type credentialRule struct {
+	Host     string            `yaml:"host"`
+	Regex    string            `yaml:"regex"`
+	Provider string            `yaml:"provider"`
+	Params   map[string]string `yaml:"params"`
+
+	regex *regexp.Regexp
+}
+
+// credentialRuleProvider describes the parameters accepted by a rule provider, and how it retrieves credentials.
+type credentialRuleProvider struct {
+	Params   []string
+	Validate func(params map[string]string) error
+	Get      func(ctx context.Context, hostname string, params map[string]string) (username, password string, found bool, err error)
+}
+
+// credentialRuleProviders are the providers that may be named in a rule.
+var credentialRuleProviders = map[string]credentialRuleProvider{
+	"env": {
+		Params:   []string{"username", "password", "token"},
+		Validate: validateEnvRule,
+		Get:      getEnvRuleCredentials,
+	},
+	"file": {
+		Params:   []string{"username", "password_file", "token_file"},
+		Validate: validateFileRule,
+		Get:      getFileRuleCredentials,
+	},
+	"exec": {
+		Params:   []string{"username", "command"},
+		Validate: validateExecRule,
+		Get:      getExecRuleCredentials,
+	},
+	"github": {
+		Params: []string{"username", "token"},
+		Get:    getGitHubRuleCredentials,
+	},
+	"plugin": {
+		Params:   []string{"name", "path", "timeout"},
+		Validate: validatePluginRule,
+		Get:      getPluginRuleCredentials,
+	},
+	"ecr": {
+		Params:   []string{"account", "region"},
+		Validate: validateEcrRule,
+		Get:      getEcrRuleCredentials,
+	},
+}
+
+// configError is a schema error at a position in the configuration file.
+type configError struct {
+	Path    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *configError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.Path, e.Line, e.Column, e.Message)
+}
+
+// getCredentialConfigPath returns the path of the configuration file, and whether it was explicitly configured
+// with DOCKER_CREDENTIAL_ENV_CONFIG rather than defaulting to $XDG_CONFIG_HOME/docker-credential-env/config.yaml.
+func getCredentialConfigPath() (configPath string, explicit bool, err error) {
+	if configPath = os.Getenv(envConfig); configPath != "" {
+		return configPath, true, nil
+	}
+
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		if configDir, err = os.UserConfigDir(); err != nil {
+			return "", false, err
+		}
+	}
+	return filepath.Join(configDir, "docker-credential-env", "config.yaml"), false, nil
+}
+
+// loadCredentialConfig loads the configuration file, returning nil if it is not configured and does not exist.
+func loadCredentialConfig() (*credentialConfig, error) {
+	configPath, explicit, err := getCredentialConfigPath()
+	if err != nil {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(configPath) // #nosec G304 -- path is supplied by the operator
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file %q: %w", configPath, err)
+	}
+
+	return parseCredentialConfig(configPath, data)
+}
+
+// parseCredentialConfig decodes and validates a configuration file.
+// Schema errors are returned as *configError, joined with errors.Join if there are several.
+func parseCredentialConfig(configPath string, data []byte) (*credentialConfig, error) {
+	var config credentialConfig
+	if err := yaml.UnmarshalWithOptions(data, &config, yaml.Strict()); err != nil {
+		var yamlErr yaml.Error
+		if errors.As(err, &yamlErr) && yamlErr.GetToken() != nil {
+			position := yamlErr.GetToken().Position
+			return nil, &configError{Path: configPath, Line: position.Line, Column: position.Column, Message: yamlErr.GetMessage()}
+		}
+		return nil, fmt.Errorf("failed to parse configuration file %q: %w", configPath, err)
+	}
+
+	file, err := parser.ParseBytes(data, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %q: %w", configPath, err)
+	}
+
+	var errs []error
+	invalid := func(query, key, format string, args ...any) {
+		line, column := nodePosition(file, query, key)
+		errs = append(errs, &configError{Path: configPath, Line: line, Column: column, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		query := fmt.Sprintf("$.rules[%d]", i)
+
+		switch {
+		case rule.Host == "" && rule.Regex == "":
+			invalid(query, "", "rule must have either host or regex")
+		case rule.Host != "" && rule.Regex != "":
+			invalid(query, "regex", "rule must not have both host and regex")
+		case rule.Host != "":
+			if _, err := path.Match(rule.Host, ""); err != nil {
+				invalid(query, "host", "invalid host glob %q: %v", rule.Host, err)
+			}
+		default:
+			if rule.regex, err = regexp.Compile("^(?:" + rule.Regex + ")$"); err != nil {
+				invalid(query, "regex", "invalid regex %q: %v", rule.Regex, err)
+			}
+		}
+
+		provider, ok := credentialRuleProviders[rule.Provider]
+		if !ok {
+			if rule.Provider == "" {
+				invalid(query, "", "rule must have a provider")
+			} else {
+				invalid(query, "provider", "unknown provider %q, expected one of: %s",
+					rule.Provider, strings.Join(slices.Sorted(maps.Keys(credentialRuleProviders)), ", "))
+			}
+			continue
+		}
+
+		unknown := false
+		for _, param := range slices.Sorted(maps.Keys(rule.Params)) {
+			if !slices.Contains(provider.Params, param) {
+				invalid(query+".params", param, "unknown %s parameter %q, expected one of: %s", rule.Provider, param, strings.Join(provider.Params, ", "))
+				unknown = true
+			}
+		}
+		if !unknown && provider.Validate != nil {
+			if err := provider.Validate(rule.Params); err != nil {
+				if rule.Params != nil {
+					query += ".params"
+				}
+				invalid(query, "", "%s: %v", rule.Provider, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &config, nil
+}
+
+// nodePosition returns the line and column of the node at the given YAML path, or 0 if there is no such node.
+// For a mapping, the position of the given key is returned, or that of its first key if the key is not present.
+func nodePosition(file *ast.File, query, key string) (line, column int) {
+	p, err := yaml.PathString(query)
+	if err != nil {
+		return 0, 0
+	}
+	node, err := p.FilterFile(file)
+	if err != nil || node == nil {
+		return 0, 0
+	}
+	if mapping, ok := node.(*ast.MappingNode); ok && len(mapping.Values) > 0 {
+		node = mapping.Values[0].Key
+		for _, value := range mapping.Values {
+			if value.Key.GetToken().Value == key {
+				node = value.Key
+				break
+			}
+		}
+	}
+	if node.GetToken() == nil {
+		return 0, 0
+	}
+	position := node.GetToken().Position
+	return position.Line, position.Column
+}
+
+// Matches reports whether the rule applies to the hostname.
+func (r *credentialRule) Matches(hostname string) bool {
+	if r.regex != nil {
+		return r.regex.MatchString(hostname)
+	}
+	matched, err := path.Match(r.Host, hostname)
+	return err == nil && matched
+}
+
+// getCredentials retrieves credentials for the hostname from each matching rule, in order.
+// Returns the username, password, a boolean indicating if credentials were found, and any provider error.
+func (c *credentialConfig) getCredentials(ctx context.Context, hostname string) (username, password string, found bool, err error) {
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if !rule.Matches(hostname) {
+			continue
+		}
+
+		if username, password, found, err = credentialRuleProviders[rule.Provider].Get(ctx, hostname, rule.Params); err != nil {
+			return "", "", false, fmt.Errorf("rule %d (%s): %w", i+1, rule.Provider, err)
+		}
+		if found {
+			if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+				_, _ = fmt.Fprintf(os.Stderr, "Authenticating access to %q with rule %d (%s)\n", hostname, i+1, rule.Provider)
+			}
+			return
+		}
+	}
+	return "", "", false, nil
+}
+
+// validateEnvRule requires either both of username and password, or token, or no parameters at all.
+func validateEnvRule(params map[string]string) error {
+	_, hasUsername := params["username"]
+	_, hasPassword := params["password"]
+	_, hasToken := params["token"]
+	switch {
+	case hasToken && (hasUsername || hasPassword):
+		return errors.New("token cannot be combined with username and password")
+	case hasUsername != hasPassword:
+		return errors.New("username and password must be given together")
+	}
+	return nil
+}
+
+// getEnvRuleCredentials reads the username and password, or identity token, from the named environment variables.
+// Without parameters, the standard DOCKER_<hostname>_* variables are used.
+func getEnvRuleCredentials(ctx context.Context, hostname string, params map[string]string) (username, password string, found bool, err error) {
+	if token, ok := params["token"]; ok {
+		if token, found = lookupEnvVariable(token); !found {
+			return "", "", false, nil
+		}
+		if token, err = resolveSecretRef(ctx, token); err != nil {
+			return "", "", false, err
+		}
+		return identityTokenUsername, token, true, nil
+	}
+
+	if envUsername, ok := params["username"]; ok {
+		if username, found = lookupEnvVariable(envUsername); found {
+			if password, found = lookupEnvVariable(params["password"]); found {
+				username, password, err = resolveCredentials(ctx, username, password)
+				return username, password, err == nil, err
+			}
+		}
+		return "", "", false, nil
+	}
+
+	return getEnvCredentials(ctx, hostname)
+}
+
+// validateFileRule requires either username and password_file, or token_file.
+func validateFileRule(params map[string]string) error {
+	_, hasUsername := params["username"]
+	_, hasPasswordFile := params["password_file"]
+	_, hasTokenFile := params["token_file"]
+	switch {
+	case hasTokenFile && (hasUsername || hasPasswordFile):
+		return errors.New("token_file cannot be combined with username and password_file")
+	case !hasTokenFile && !(hasUsername && hasPasswordFile):
+		return errors.New("requires either username and password_file, or token_file")
+	}
+	return nil
+}
+
+// getFileRuleCredentials reads the password or identity token from a file.
+func getFileRuleCredentials(_ context.Context, _ string, params map[string]string) (username, password string, found bool, err error) {
+	if tokenFile, ok := params["token_file"]; ok {
+		token, err := readSecretFile(tokenFile)
+		if err != nil {
+			return "", "", false, err
+		}
+		return identityTokenUsername, token, true, nil
+	}
+
+	if password, err = readSecretFile(params["password_file"]); err != nil {
+		return "", "", false, err
+	}
+	return params["username"], password, true, nil
+}
+
+// validateExecRule requires a command that can be split into arguments.
+func validateExecRule(params map[string]string) error {
+	args, err := splitCommandLine(params["command"])
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New("requires command")
+	}
+	return nil
+}
+
+// getExecRuleCredentials runs a command and returns its output as the password, or as an identity token if no
+// username is given.
+func getExecRuleCredentials(ctx context.Context, _ string, params map[string]string) (username, password string, found bool, err error) {
+	if password, err = execSecretCommand(ctx, params["command"]); err != nil {
+		return "", "", false, err
+	}
+
+	username, ok := params["username"]
+	if !ok {
+		username = identityTokenUsername
+	}
+	return username, password, true, nil
+}
+
+// getGitHubRuleCredentials reads a GitHub token from the named environment variable, GITHUB_TOKEN by default.
+func getGitHubRuleCredentials(ctx context.Context, _ string, params map[string]string) (username, password string, found bool, err error) {
+	envToken, ok := params["token"]
+	if !ok {
+		envToken = envGitHubToken
+	}
+	if password, found = lookupEnvVariable(envToken); !found {
+		return "", "", false, nil
+	}
+	if password, err = resolveSecretRef(ctx, password); err != nil {
+		return "", "", false, err
+	}
+
+	if username, ok = params["username"]; !ok {
+		username = ghcrUsername
+	}
+	return username, password, true, nil
+}
+
+// validatePluginRule requires either the name or the path of a plugin, and a valid timeout if any.
+func validatePluginRule(params map[string]string) error {
+	_, hasName := params["name"]
+	_, hasPath := params["path"]
+	if hasName == hasPath {
+		return errors.New("requires either name or path")
+	}
+	if name := params["name"]; hasName && (name == "" || strings.ContainsAny(name, `/\`)) {
+		return fmt.Errorf("invalid name %q", name)
+	}
+	if value, ok := params["timeout"]; ok {
+		if timeout, err := time.ParseDuration(value); err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid timeout %q", value)
+		}
+	}
+	return nil
+}
+
+// getPluginRuleCredentials runs an exec plugin, either docker-credential-env-<name> found on PATH or the given path.
+func getPluginRuleCredentials(ctx context.Context, hostname string, params map[string]string) (username, password string, found bool, err error) {
+	p := plugin{Name: params["name"], Path: params["path"]}
+	if value, ok := params["timeout"]; ok {
+		if p.Timeout, err = time.ParseDuration(value); err != nil {
+			return "", "", false, err
+		}
+	}
+	return p.Get(ctx, hostname, serverURLFromContext(ctx, hostname))
+}
+
+// validateEcrRule requires any account to be an AWS account ID.
+func validateEcrRule(params map[string]string) error {
+	if account, ok := params["account"]; ok && !ecrAccountID.MatchString(account) {
+		return fmt.Errorf("invalid account %q", account)
+	}
+	return nil
+}
+
+// getEcrRuleCredentials exchanges AWS credentials for an ECR login, taking the account and region from the
+// parameters or, if absent, from the hostname.
+func getEcrRuleCredentials(ctx context.Context, hostname string, params map[string]string) (username, password string, found bool, err error) {
+	provider := &ecrContext{AccountID: params["account"], Region: params["region"]}
+	if submatches := ecrHostname.FindStringSubmatch(hostname); submatches != nil {
+		if provider.AccountID == "" {
+			provider.AccountID = submatches[ecrHostname.SubexpIndex("account")]
+		}
+		if provider.Region == "" {
+			provider.Region = submatches[ecrHostname.SubexpIndex("region")]
+		}
+	}
+	if provider.AccountID == "" || provider.Region == "" {
+		return "", "", false, fmt.Errorf("account and region are required for %q", hostname)
+	}
+
+	if username, password, err = getEcrToken(ctx, provider); err != nil {
+		return "", "", false, err
+	}
+	return username, password, true, nil
+}
+
+// RunValidateCommand is the main entry point for the validate command.
+// It validates the configuration file given as the only argument, or the configured one by default.
+func RunValidateCommand(args []string, out io.Writer) error {
+	if len(args) > 1 {
+		return errors.New("too many arguments\nUsage: docker-credential-env validate [config-file]")
+	}
+
+	var configPath string
+	if len(args) == 1 {
+		configPath = args[0]
+	} else {
+		var err error
+		if configPath, _, err = getCredentialConfigPath(); err != nil {
+			return err
+		}
+	}
+
+	data, err := os.ReadFile(configPath) // #nosec G304 -- path is supplied by the operator
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file %q: %w", configPath, err)
+	}
+
+	config, err := parseCredentialConfig(configPath, data)
+	if err != nil {
+		_, _ = fmt.Fprintln(out, err)
+		return fmt.Errorf("configuration file %q is invalid", configPath)
+	}
+
+	_, err = fmt.Fprintf(out, "Configuration file %q is valid (%d rules)\n", configPath, len(config.Rules))
+	return err
+}
+
+// configProvider consults the rules of the configuration file.
+type configProvider struct{}
+
+// Match implements Provider.
+func (*configProvider) Match(string) bool { return true }
+
+// Get implements Provider.
+// An invalid configuration file at the default path is ignored, so that it cannot break other providers; one named
+// by DOCKER_CREDENTIAL_ENV_CONFIG is an error.
+func (*configProvider) Get(ctx context.Context, host string) (username, password string, found bool, err error) {
+	config, err := loadCredentialConfig()
+	if err != nil {
+		if _, explicit, pathErr := getCredentialConfigPath(); pathErr == nil && !explicit {
+			if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+				_, _ = fmt.Fprintf(os.Stderr, "Warning: ignoring credential rules file: %s\n", strings.ReplaceAll(err.Error(), "\n", "; "))
+			}
+			return "", "", false, nil
+		}
+		return "", "", false, err
+	}
+	if config == nil {
+		return "", "", false, nil
+	}
+	return config.getCredentials(ctx, host)
+}
+
+// Describe implements Provider.
+func (*configProvider) Describe() string { return "credential rules file" }
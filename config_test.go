@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCredentialConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    int
+		errContains []string
+	}{
+		{
+			name:     "Empty",
+			input:    "",
+			expected: 0,
+		},
+		{
+			name: "Valid",
+			input: `rules:
+  - host: "*.example.com"
+    provider: env
+    params:
+      username: CI_USER
+      password: CI_PASSWORD
+  - regex: 'repo[0-9]+\.example\.net'
+    provider: file
+    params:
+      token_file: /run/secrets/token
+  - host: ghcr.io
+    provider: github
+  - host: "*.amazonaws.com"
+    provider: ecr
+`,
+			expected: 4,
+		},
+		{
+			name: "Unknown field",
+			input: `rules:
+  - host: example.com
+    provider: env
+    hostname: example.com
+`,
+			errContains: []string{"config.yaml:4:5:", "unknown field"},
+		},
+		{
+			name: "Schema errors",
+			input: `rules:
+  - provider: env
+  - host: example.com
+    regex: example\.com
+    provider: env
+  - host: example.com
+    provider: vault
+  - host: example.com
+    provider: file
+    params:
+      username: u
+      password: p
+  - host: example.com
+    provider: ecr
+    params:
+      account: "123"
+`,
+			errContains: []string{
+				"config.yaml:2:5: rule must have either host or regex",
+				"config.yaml:4:5: rule must not have both host and regex",
+				`config.yaml:7:5: unknown provider "vault"`,
+				`config.yaml:12:7: unknown file parameter "password"`,
+				`config.yaml:16:7: ecr: invalid account "123"`,
+			},
+		},
+		{
+			name: "Invalid regex",
+			input: `rules:
+  - regex: "("
+    provider: github
+`,
+			errContains: []string{"config.yaml:2:5: invalid regex"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := parseCredentialConfig("config.yaml", []byte(tt.input))
+			if len(tt.errContains) > 0 {
+				if err == nil {
+					t.Fatal("expected an error but got none")
+				}
+				for _, s := range tt.errContains {
+					if !strings.Contains(err.Error(), s) {
+						t.Errorf("Expected error to contain %q, but got %v", s, err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCredentialConfig() unexpected error: %v", err)
+			}
+			if len(actual.Rules) != tt.expected {
+				t.Errorf("parseCredentialConfig() actual = (%v rules), expected (%v rules)", len(actual.Rules), tt.expected)
+			}
+		})
+	}
+}
+
+func TestEnvGet_Config(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	writeFile(t, tokenFile, "t1\n")
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, configFile, `rules:
+  - host: "*.example.com"
+    provider: env
+    params:
+      username: CI_USER
+      password: CI_PASSWORD
+  - regex: 'repo[0-9]+\.example\.net'
+    provider: file
+    params:
+      token_file: `+tokenFile+`
+  - host: "*.example.org"
+    provider: env
+  - host: ghcr.io
+    provider: github
+    params:
+      token: CI_GITHUB_TOKEN
+`)
+
+	type output struct {
+		username string
+		password string
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected output
+	}{
+		{
+			name:     "Glob rule",
+			input:    "https://repo.example.com",
+			expected: output{username: "u1", password: "p1"},
+		},
+		{
+			name:     "Glob rule does not match",
+			input:    "https://example.com",
+			expected: output{username: "u0", password: "p0"},
+		},
+		{
+			name:     "Regex rule",
+			input:    "https://repo1.example.net",
+			expected: output{username: "<token>", password: "t1"},
+		},
+		{
+			name:     "Regex rule must match whole hostname",
+			input:    "https://repo1.example.net.evil.com",
+			expected: output{},
+		},
+		{
+			name:     "Standard env lookup",
+			input:    "https://repo.example.org",
+			expected: output{username: "u2", password: "p2"},
+		},
+		{
+			name:     "Custom GitHub token",
+			input:    "https://ghcr.io",
+			expected: output{username: "x-access-token", password: "t2"},
+		},
+	}
+
+	t.Setenv("DOCKER_CREDENTIAL_ENV_CONFIG", configFile)
+	t.Setenv("CI_USER", "u1")
+	t.Setenv("CI_PASSWORD", "p1")
+	t.Setenv("CI_GITHUB_TOKEN", "t2")
+	t.Setenv("GITHUB_TOKEN", "t0")
+	t.Setenv("DOCKER_example_com_USR", "u0")
+	t.Setenv("DOCKER_example_com_PSW", "p0")
+	t.Setenv("DOCKER_example_org_USR", "u2")
+	t.Setenv("DOCKER_example_org_PSW", "p2")
+
+	e := Env{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actualUsername, actualPassword, err := e.Get(tt.input)
+			if err != nil {
+				t.Fatalf("Get(%v) unexpected error: %v", tt.input, err)
+			}
+			if actualUsername != tt.expected.username || actualPassword != tt.expected.password {
+				t.Errorf("Get(%v) actual = (%v, %v), expected (%v, %v)", tt.input, actualUsername, actualPassword, tt.expected.username, tt.expected.password)
+			}
+		})
+	}
+
+	t.Run("Missing explicit config", func(t *testing.T) {
+		t.Setenv("DOCKER_CREDENTIAL_ENV_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+		if _, _, err := e.Get("https://repo.example.com"); err == nil {
+			t.Error("expected an error but got none")
+		}
+	})
+
+	t.Run("Invalid explicit config", func(t *testing.T) {
+		invalidFile := filepath.Join(t.TempDir(), "config.yaml")
+		writeFile(t, invalidFile, "rules:\n  - host: [\n")
+		t.Setenv("DOCKER_CREDENTIAL_ENV_CONFIG", invalidFile)
+		if _, _, err := e.Get("https://repo.example.com"); err == nil {
+			t.Error("expected an error but got none")
+		}
+	})
+
+	t.Run("Invalid default config", func(t *testing.T) {
+		configDir := t.TempDir()
+		if err := os.Mkdir(filepath.Join(configDir, "docker-credential-env"), 0700); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(configDir, "docker-credential-env", "config.yaml"), "rules:\n  - host: [\n")
+		t.Setenv("DOCKER_CREDENTIAL_ENV_CONFIG", "")
+		t.Setenv("XDG_CONFIG_HOME", configDir)
+		if username, password, err := e.Get("https://repo.example.com"); err != nil || username != "u0" || password != "p0" {
+			t.Errorf("Get() actual = (%v, %v, %v), expected (u0, p0, <nil>)", username, password, err)
+		}
+	})
+
+	t.Run("Missing default config", func(t *testing.T) {
+		t.Setenv("DOCKER_CREDENTIAL_ENV_CONFIG", "")
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+		if username, password, err := e.Get("https://repo.example.com"); err != nil || username != "u0" || password != "p0" {
+			t.Errorf("Get() actual = (%v, %v, %v), expected (u0, p0, <nil>)", username, password, err)
+		}
+	})
+}
+
+func TestRunValidateCommand(t *testing.T) {
+	validFile := filepath.Join(t.TempDir(), "valid.yaml")
+	writeFile(t, validFile, "rules:\n  - host: ghcr.io\n    provider: github\n")
+	invalidFile := filepath.Join(t.TempDir(), "invalid.yaml")
+	writeFile(t, invalidFile, "rules:\n  - host: ghcr.io\n    provider: gitea\n")
+
+	var out bytes.Buffer
+	if err := RunValidateCommand([]string{validFile}, &out); err != nil {
+		t.Fatalf("RunValidateCommand() unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "is valid (1 rules)") {
+		t.Errorf("RunValidateCommand() actual = (%q), expected it to report a valid file", out.String())
+	}
+
+	out.Reset()
+	t.Setenv("DOCKER_CREDENTIAL_ENV_CONFIG", invalidFile)
+	if err := RunValidateCommand(nil, &out); err == nil {
+		t.Fatal("expected an error but got none")
+	}
+	if expected := invalidFile + `:3:5: unknown provider "gitea"`; !strings.Contains(out.String(), expected) {
+		t.Errorf("RunValidateCommand() actual = (%q), expected it to contain (%q)", out.String(), expected)
+	}
+}
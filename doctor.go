@@ -0,0 +1,229 @@
+// Package main provides the doctor command, which diagnoses common installation problems.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/docker/cli/cli/config/configfile"
+)
+
+// Severities of doctor findings.
+const (
+	doctorOK      = "ok"
+	doctorWarning = "warning"
+	doctorError   = "error"
+)
+
+// doctorFinding is the outcome of a single check, with a remediation hint for problems.
+type doctorFinding struct {
+	Severity string
+	Message  string
+	Hint     string
+}
+
+// doctorCmd handles the logic for the "doctor" command.
+type doctorCmd struct {
+	Out        io.Writer
+	configPath string
+	findings   []doctorFinding
+}
+
+// report records a finding.
+func (c *doctorCmd) report(severity, hint, format string, args ...any) {
+	c.findings = append(c.findings, doctorFinding{Severity: severity, Message: fmt.Sprintf(format, args...), Hint: hint})
+}
+
+// Run performs all checks and prints the findings.
+// Returns an error if any check found an error.
+func (c *doctorCmd) Run() error {
+	c.checkExecutable()
+	if config := c.checkConfigFile(); config != nil {
+		c.checkCredentialStore(config)
+		c.checkAuths(config)
+		c.checkRegistries(config)
+	}
+	c.checkSettings()
+
+	var errorCount, warningCount int
+	for _, finding := range c.findings {
+		_, _ = fmt.Fprintf(c.Out, "[%s] %s\n", finding.Severity, finding.Message)
+		if finding.Hint != "" {
+			_, _ = fmt.Fprintf(c.Out, "    hint: %s\n", finding.Hint)
+		}
+		switch finding.Severity {
+		case doctorError:
+			errorCount++
+		case doctorWarning:
+			warningCount++
+		}
+	}
+	_, _ = fmt.Fprintf(c.Out, "\n%d errors, %d warnings\n", errorCount, warningCount)
+
+	if errorCount > 0 {
+		return fmt.Errorf("found %d errors", errorCount)
+	}
+	return nil
+}
+
+// checkExecutable checks that Docker can run this helper as docker-credential-env.
+func (c *doctorCmd) checkExecutable() {
+	path, err := exec.LookPath(helperPrefix + "env")
+	switch {
+	case err != nil:
+		c.report(doctorError, "install the binary to a directory on PATH, named "+helperPrefix+"env",
+			"%senv was not found on PATH", helperPrefix)
+	case !isSelf(helperPrefix + "env"):
+		c.report(doctorWarning, "remove or update the other copy, so that Docker runs this version",
+			"%senv on PATH is %q, which is not this executable", helperPrefix, path)
+	default:
+		c.report(doctorOK, "", "%senv found on PATH at %q", helperPrefix, path)
+	}
+}
+
+// checkConfigFile checks the location and readability of the Docker config file, returning it if it can be loaded.
+func (c *doctorCmd) checkConfigFile() *configfile.ConfigFile {
+	if dockerConfigDir := os.Getenv("DOCKER_CONFIG"); dockerConfigDir != "" {
+		if info, err := os.Stat(dockerConfigDir); err != nil || !info.IsDir() {
+			c.report(doctorError, "unset DOCKER_CONFIG, or point it to the directory containing config.json",
+				"DOCKER_CONFIG is set to %q, which is not a directory", dockerConfigDir)
+			return nil
+		}
+		c.report(doctorOK, "", "DOCKER_CONFIG is set to %q", dockerConfigDir)
+	}
+
+	if _, err := os.Stat(c.configPath); os.IsNotExist(err) {
+		c.report(doctorWarning, "run `docker-credential-env setup default` or `docker-credential-env setup <registry>`",
+			"Docker config file %q does not exist", c.configPath)
+		return nil
+	}
+
+	setup := &setupCmd{configPath: c.configPath}
+	config, err := setup.loadConfig()
+	if err != nil {
+		c.report(doctorError, "check the file permissions and JSON syntax", "%v", err)
+		return nil
+	}
+	c.report(doctorOK, "", "Docker config file %q is readable", c.configPath)
+	return config
+}
+
+// envRegistries returns the registries configured to use the env credential helper, sorted.
+func envRegistries(config *configfile.ConfigFile) []string {
+	var registries []string
+	for registry, helper := range config.CredentialHelpers {
+		if helper == "env" {
+			registries = append(registries, registry)
+		}
+	}
+	slices.Sort(registries)
+	return registries
+}
+
+// checkCredentialStore checks that the env credential helper is configured as the default or for some registries.
+func (c *doctorCmd) checkCredentialStore(config *configfile.ConfigFile) {
+	registries := envRegistries(config)
+	switch {
+	case config.CredentialsStore == "env":
+		c.report(doctorOK, "", "credsStore is set to \"env\"")
+	case config.CredentialsStore != "" && len(registries) == 0:
+		c.report(doctorError, "run `docker-credential-env setup default`, or set "+envFallback+"="+config.CredentialsStore+" to keep it as a fallback",
+			"credsStore is set to %q, and no registries use the \"env\" credential helper", config.CredentialsStore)
+	case config.CredentialsStore != "":
+		c.report(doctorWarning, "run `docker-credential-env setup <registry>` for other registries that should use it",
+			"credsStore is set to %q, so only %d registries listed in credHelpers use the \"env\" credential helper", config.CredentialsStore, len(registries))
+	case len(registries) == 0:
+		c.report(doctorError, "run `docker-credential-env setup default` or `docker-credential-env setup <registry>`",
+			"the \"env\" credential helper is not configured in %q", c.configPath)
+	default:
+		c.report(doctorOK, "", "%d registries use the \"env\" credential helper", len(registries))
+	}
+}
+
+// checkAuths reports auths entries holding stored credentials for registries that do not use the helper.
+// Docker prefers a registry's credential helper, or the credential store, over its auths entry, so entries
+// for registries that resolve to the env credential helper are ignored.
+func (c *doctorCmd) checkAuths(config *configfile.ConfigFile) {
+	var stale []string
+	for registry, auth := range config.AuthConfigs {
+		helper, ok := config.CredentialHelpers[registry]
+		if !ok {
+			helper = config.CredentialsStore
+		}
+		if helper == "env" {
+			continue
+		}
+		if auth.Auth != "" || auth.Password != "" || auth.IdentityToken != "" {
+			stale = append(stale, registry)
+		}
+	}
+	slices.Sort(stale)
+	for _, registry := range stale {
+		c.report(doctorWarning, "run `docker logout "+registry+"`, or remove the entry from \"auths\"",
+			"auths entry for %q contains stored credentials, and the registry does not use the \"env\" credential helper", registry)
+	}
+}
+
+// checkRegistries checks that credentials can be resolved for each registry configured to use the env credential helper,
+// or for each registry listed in auths if env is the default credential store.
+func (c *doctorCmd) checkRegistries(config *configfile.ConfigFile) {
+	registries := envRegistries(config)
+	if config.CredentialsStore == "env" {
+		for registry := range config.AuthConfigs {
+			if _, ok := config.CredentialHelpers[registry]; !ok {
+				registries = append(registries, registry)
+			}
+		}
+		slices.Sort(registries)
+	}
+
+	for _, registry := range registries {
+		_, _, found, err := resolveServerCredentials(context.Background(), registry)
+		switch {
+		case err != nil:
+			c.report(doctorError, "run `docker-credential-env resolve "+registry+"` for details",
+				"failed to resolve credentials for %q: %v", registry, err)
+		case !found:
+			c.report(doctorWarning, "set the credential variables, or run `docker-credential-env resolve "+registry+"` to list them",
+				"no credentials found for %q", registry)
+		default:
+			c.report(doctorOK, "", "credentials found for %q", registry)
+		}
+	}
+}
+
+// checkSettings checks the helper's own configuration: the provider order and the credential rules file.
+func (c *doctorCmd) checkSettings() {
+	if _, err := getProviders(); err != nil {
+		c.report(doctorError, "fix "+envProviders, "%v", err)
+	}
+	if _, err := loadCredentialConfig(); err != nil {
+		configPath, _, _ := getCredentialConfigPath()
+		hint := "run `docker-credential-env validate " + configPath + "` for details"
+		if errors.Is(err, os.ErrNotExist) {
+			hint = "create the file, or unset " + envConfig
+		}
+		c.report(doctorError, hint, "%s", strings.ReplaceAll(err.Error(), "\n", "; "))
+	}
+}
+
+// RunDoctorCommand is the main entry point for the doctor command.
+func RunDoctorCommand(args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments\nUsage: docker-credential-env doctor")
+	}
+
+	cmd := &doctorCmd{Out: out}
+
+	var err error
+	if cmd.configPath, err = getDockerConfigPath(); err != nil {
+		return err
+	}
+	return cmd.Run()
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunDoctorCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake executable requires a POSIX system")
+	}
+
+	binDir := t.TempDir()
+	writeFile(t, filepath.Join(binDir, "docker-credential-env"), "#!/bin/sh\n")
+	if err := os.Chmod(filepath.Join(binDir, "docker-credential-env"), 0700); err != nil { // #nosec G302
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		config      string
+		dockerDir   string
+		env         map[string]string
+		expected    []string
+		unexpected  []string
+		errContains string
+	}{
+		{
+			name:   "Healthy installation",
+			path:   binDir,
+			config: `{"auths": {"registry.example.com": {}}, "credsStore": "env"}`,
+			env:    map[string]string{"DOCKER_registry_example_com_USR": "u1", "DOCKER_registry_example_com_PSW": "p1"},
+			expected: []string{
+				"[warning] docker-credential-env on PATH is",
+				`[ok] credsStore is set to "env"`,
+				`[ok] credentials found for "registry.example.com"`,
+				"0 errors, 1 warnings",
+			},
+		},
+		{
+			name:        "Executable not on PATH",
+			path:        t.TempDir(),
+			config:      `{"credsStore": "env"}`,
+			expected:    []string{"[error] docker-credential-env was not found on PATH", "hint: install the binary"},
+			errContains: "found 1 errors",
+		},
+		{
+			name:        "Another credential store",
+			path:        binDir,
+			config:      `{"credsStore": "desktop"}`,
+			expected:    []string{`[error] credsStore is set to "desktop", and no registries use the "env" credential helper`, "DOCKER_CREDENTIAL_ENV_FALLBACK=desktop"},
+			errContains: "found 1 errors",
+		},
+		{
+			name:   "Another credential store with registries",
+			path:   binDir,
+			config: `{"credsStore": "desktop", "credHelpers": {"ghcr.io": "env"}}`,
+			env:    map[string]string{"GITHUB_TOKEN": "t1"},
+			expected: []string{
+				`[warning] credsStore is set to "desktop", so only 1 registries listed in credHelpers use the "env" credential helper`,
+				`[ok] credentials found for "ghcr.io"`,
+			},
+		},
+		{
+			name:        "Not configured",
+			path:        binDir,
+			config:      `{}`,
+			expected:    []string{`[error] the "env" credential helper is not configured`},
+			errContains: "found 1 errors",
+		},
+		{
+			name:   "Stale auths",
+			path:   binDir,
+			config: `{"auths": {"registry.example.org": {"auth": "dTpw"}, "other.example.org": {"auth": "dTpw"}}, "credHelpers": {"registry.example.org": "env"}}`,
+			expected: []string{
+				`[warning] auths entry for "other.example.org" contains stored credentials`,
+				"hint: run `docker logout other.example.org`",
+				`[warning] no credentials found for "registry.example.org"`,
+			},
+			unexpected: []string{`auths entry for "registry.example.org"`},
+		},
+		{
+			name:   "Auths behind the env credential store",
+			path:   binDir,
+			config: `{"auths": {"registry.example.com": {"auth": "dTpw"}, "gcr.io": {"auth": "dTpw"}}, "credsStore": "env", "credHelpers": {"gcr.io": "gcloud"}}`,
+			env:    map[string]string{"DOCKER_registry_example_com_USR": "u1", "DOCKER_registry_example_com_PSW": "p1"},
+			expected: []string{
+				`[ok] credentials found for "registry.example.com"`,
+				`[warning] auths entry for "gcr.io" contains stored credentials`,
+			},
+			unexpected: []string{`auths entry for "registry.example.com"`},
+		},
+		{
+			name:        "Unreadable config",
+			path:        binDir,
+			config:      `{"credsStore": `,
+			expected:    []string{"[error] failed to parse Docker config file", "hint: check the file permissions and JSON syntax"},
+			errContains: "found 1 errors",
+		},
+		{
+			name:        "DOCKER_CONFIG is not a directory",
+			path:        binDir,
+			dockerDir:   filepath.Join(t.TempDir(), "missing"),
+			expected:    []string{"[error] DOCKER_CONFIG is set to", "which is not a directory"},
+			errContains: "found 1 errors",
+		},
+		{
+			name:        "Credential resolution error",
+			path:        binDir,
+			config:      `{"credHelpers": {"registry.example.com": "env"}}`,
+			env:         map[string]string{"DOCKER_registry_example_com_TOKEN_FILE": "/nonexistent/token"},
+			expected:    []string{`[error] failed to resolve credentials for "registry.example.com"`, "hint: run `docker-credential-env resolve registry.example.com`"},
+			errContains: "found 1 errors",
+		},
+		{
+			name:        "Unknown provider",
+			path:        binDir,
+			config:      `{"credsStore": "env"}`,
+			env:         map[string]string{"DOCKER_CREDENTIAL_ENV_PROVIDERS": "env,harbor"},
+			expected:    []string{`[error] unknown provider "harbor"`},
+			errContains: "found 1 errors",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupTestEnvironment(t)
+			if tt.dockerDir != "" {
+				t.Setenv("DOCKER_CONFIG", tt.dockerDir)
+			} else {
+				writeFile(t, filepath.Join(tempDir, "config.json"), tt.config)
+			}
+			t.Setenv("PATH", tt.path)
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			out := new(bytes.Buffer)
+			err := RunDoctorCommand(nil, out)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("Expected error to contain %q, but got %v", tt.errContains, err)
+				}
+			} else if err != nil {
+				t.Errorf("RunDoctorCommand() unexpected error: %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("RunDoctorCommand() output does not contain %q:\n%s", expected, out.String())
+				}
+			}
+			for _, unexpected := range tt.unexpected {
+				if strings.Contains(out.String(), unexpected) {
+					t.Errorf("RunDoctorCommand() output contains %q:\n%s", unexpected, out.String())
+				}
+			}
+		})
+	}
+
+	t.Run("Too many arguments", func(t *testing.T) {
+		if err := RunDoctorCommand([]string{"extra"}, new(bytes.Buffer)); err == nil || !strings.Contains(err.Error(), "too many arguments") {
+			t.Errorf("Expected error to contain %q, but got %v", "too many arguments", err)
+		}
+	})
+}
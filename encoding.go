@@ -0,0 +1,117 @@
+// Package main provides environment variable name encodings and lookups.
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// envEncoding converts hostname labels into the hostname part of environment variable names.
+type envEncoding func(labels []string) string
+
+// legacyEncoding replaces hyphens with underscores and joins labels with underscores, such that
+// "my-reg.example.com" becomes "my_reg_example_com". Hostnames differing only in their use of
+// '.', '-' and '_' share the same encoding.
+func legacyEncoding(labels []string) string {
+	return strings.ReplaceAll(strings.Join(labels, envSeparator), "-", "_")
+}
+
+// strictEncoding is an unambiguous encoding in which labels are joined with "__", and every character
+// other than an ASCII letter or digit is encoded as "_XX_", its uppercase hexadecimal value, such that
+// "my-reg.example.com" becomes "my_2D_reg__example__com" and "my_reg.example.com" becomes "my_5F_reg__example__com".
+// IPv6 literals encode each ':' as "_3A_". Internationalised hostnames must be given in their ASCII
+// (punycode) form, as used by Docker; any remaining non-ASCII bytes are encoded individually.
+func strictEncoding(labels []string) string {
+	encoded := make([]string, len(labels))
+	for i, label := range labels {
+		var b strings.Builder
+		for _, c := range []byte(label) {
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+				b.WriteByte(c)
+			default:
+				_, _ = fmt.Fprintf(&b, "_%02X_", c)
+			}
+		}
+		encoded[i] = b.String()
+	}
+	return strings.Join(encoded, envSeparator+envSeparator)
+}
+
+// strictEncodingEnabled reports whether strict encoding has been enabled with DOCKER_CREDENTIAL_ENV_STRICT_ENCODING.
+func strictEncodingEnabled() bool {
+	b, err := strconv.ParseBool(os.Getenv(envStrictEncoding))
+	return err == nil && b
+}
+
+// getEnvEncodings returns the encodings to try, in order: strict encoding if enabled, then legacy encoding.
+func getEnvEncodings() []envEncoding {
+	if strictEncodingEnabled() {
+		return []envEncoding{strictEncoding, legacyEncoding}
+	}
+	return []envEncoding{legacyEncoding}
+}
+
+// isAmbiguousLegacy reports whether the legacy encoding of the labels could also have come from a different hostname.
+func isAmbiguousLegacy(labels []string) bool {
+	for _, label := range labels {
+		if strings.ContainsAny(label, "-_") {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupEnvVariable retrieves the value of the environment variable with the given name, or of its fully
+// uppercased form for CI systems and shells that only export uppercase names. If case-insensitive lookup has been
+// enabled with DOCKER_CREDENTIAL_ENV_CASE_INSENSITIVE, any other case variant is matched, with the lexically
+// smallest name winning if there are several.
+func lookupEnvVariable(name string) (string, bool) {
+	b, err := strconv.ParseBool(os.Getenv(envCaseInsensitive))
+	caseInsensitive := err == nil && b
+
+	for _, key := range []string{name, strings.ToUpper(name)} {
+		if value, found := os.LookupEnv(key); found {
+			reportCaseVariants(name, key, caseInsensitive)
+			return value, true
+		}
+	}
+
+	if !caseInsensitive {
+		return "", false
+	}
+
+	matches := getCaseVariants(name, true)
+	if len(matches) == 0 {
+		return "", false
+	}
+	reportCaseVariants(name, matches[0], true)
+	return os.LookupEnv(matches[0])
+}
+
+// getCaseVariants returns the sorted names of the set environment variables that lookupEnvVariable may match for
+// name: the name itself and its uppercase form, and any other case variant if caseInsensitive.
+func getCaseVariants(name string, caseInsensitive bool) (matches []string) {
+	upper := strings.ToUpper(name)
+	for _, entry := range os.Environ() {
+		key, _, ok := strings.Cut(entry, "=")
+		if ok && (key == name || key == upper || (caseInsensitive && strings.EqualFold(key, name))) {
+			matches = append(matches, key)
+		}
+	}
+	slices.Sort(matches)
+	return slices.Compact(matches)
+}
+
+// reportCaseVariants notes in debug mode that the variable used for name shadows other case variants that are set.
+func reportCaseVariants(name, used string, caseInsensitive bool) {
+	if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err != nil || !b {
+		return
+	}
+	if matches := getCaseVariants(name, caseInsensitive); len(matches) > 1 {
+		_, _ = fmt.Fprintf(os.Stderr, "Multiple case variants of %s found (%s); using %s\n", name, strings.Join(matches, ", "), used)
+	}
+}
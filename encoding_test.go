@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLookupEnvVariable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("environment variables are case-insensitive on Windows")
+	}
+
+	type output struct {
+		value string
+		found bool
+	}
+
+	tests := []struct {
+		name            string
+		input           string
+		caseInsensitive bool
+		expected        output
+	}{
+		{
+			name:     "Exact match",
+			input:    "DOCKER_exact_example_com_USR",
+			expected: output{value: "exact", found: true},
+		},
+		{
+			name:     "Exact match has higher priority than uppercase",
+			input:    "DOCKER_both_example_com_USR",
+			expected: output{value: "exact", found: true},
+		},
+		{
+			name:     "Uppercase",
+			input:    "DOCKER_upper_example_com_USR",
+			expected: output{value: "upper", found: true},
+		},
+		{
+			name:     "Mixed case disabled",
+			input:    "DOCKER_mixed_example_com_USR",
+			expected: output{found: false},
+		},
+		{
+			name:            "Mixed case",
+			input:           "DOCKER_mixed_example_com_USR",
+			caseInsensitive: true,
+			expected:        output{value: "mixed", found: true},
+		},
+		{
+			name:            "Lexically smallest case variant wins",
+			input:           "DOCKER_multi_example_com_USR",
+			caseInsensitive: true,
+			expected:        output{value: "first", found: true},
+		},
+		{
+			name:            "Missing",
+			input:           "DOCKER_missing_example_com_USR",
+			caseInsensitive: true,
+			expected:        output{found: false},
+		},
+	}
+
+	t.Setenv("DOCKER_exact_example_com_USR", "exact")
+	t.Setenv("DOCKER_both_example_com_USR", "exact")
+	t.Setenv("DOCKER_BOTH_EXAMPLE_COM_USR", "upper")
+	t.Setenv("DOCKER_UPPER_EXAMPLE_COM_USR", "upper")
+	t.Setenv("Docker_Mixed_Example_Com_Usr", "mixed")
+	t.Setenv("docker_multi_example_com_usr", "second")
+	t.Setenv("Docker_Multi_Example_Com_Usr", "first")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DOCKER_CREDENTIAL_ENV_CASE_INSENSITIVE", strconv.FormatBool(tt.caseInsensitive))
+
+			actualValue, actualFound := lookupEnvVariable(tt.input)
+			if actualValue != tt.expected.value || actualFound != tt.expected.found {
+				t.Errorf("lookupEnvVariable(%v) actual = (%v, %v), expected (%v, %v)", tt.input, actualValue, actualFound, tt.expected.value, tt.expected.found)
+			}
+		})
+	}
+}
+
+func TestLookupEnvVariable_CaseVariantsNotice(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("environment variables are case-insensitive on Windows")
+	}
+
+	tests := []struct {
+		name            string
+		input           string
+		caseInsensitive bool
+		expected        string
+	}{
+		{
+			name:     "Exact match shadows uppercase",
+			input:    "DOCKER_both_example_com_USR",
+			expected: "Multiple case variants of DOCKER_both_example_com_USR found (DOCKER_BOTH_EXAMPLE_COM_USR, DOCKER_both_example_com_USR); using DOCKER_both_example_com_USR",
+		},
+		{
+			name:            "Uppercase shadows mixed case",
+			input:           "DOCKER_upper_example_com_USR",
+			caseInsensitive: true,
+			expected:        "Multiple case variants of DOCKER_upper_example_com_USR found (DOCKER_UPPER_EXAMPLE_COM_USR, Docker_Upper_Example_Com_Usr); using DOCKER_UPPER_EXAMPLE_COM_USR",
+		},
+		{
+			name:            "Lexically smallest case variant wins",
+			input:           "DOCKER_multi_example_com_USR",
+			caseInsensitive: true,
+			expected:        "Multiple case variants of DOCKER_multi_example_com_USR found (Docker_Multi_Example_Com_Usr, docker_multi_example_com_usr); using Docker_Multi_Example_Com_Usr",
+		},
+		{
+			name:  "Single variant",
+			input: "DOCKER_exact_example_com_USR",
+		},
+	}
+
+	t.Setenv("DOCKER_CREDENTIAL_ENV_DEBUG", "true")
+	t.Setenv("DOCKER_exact_example_com_USR", "exact")
+	t.Setenv("DOCKER_both_example_com_USR", "exact")
+	t.Setenv("DOCKER_BOTH_EXAMPLE_COM_USR", "upper")
+	t.Setenv("DOCKER_UPPER_EXAMPLE_COM_USR", "upper")
+	t.Setenv("Docker_Upper_Example_Com_Usr", "mixed")
+	t.Setenv("docker_multi_example_com_usr", "second")
+	t.Setenv("Docker_Multi_Example_Com_Usr", "first")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DOCKER_CREDENTIAL_ENV_CASE_INSENSITIVE", strconv.FormatBool(tt.caseInsensitive))
+
+			stderr, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func(original *os.File) { os.Stderr = original }(os.Stderr)
+			os.Stderr = stderr
+
+			lookupEnvVariable(tt.input)
+
+			_ = stderr.Close()
+			data, err := os.ReadFile(stderr.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := strings.TrimSpace(string(data)); actual != tt.expected {
+				t.Errorf("lookupEnvVariable(%v) debug output actual = (%q), expected (%q)", tt.input, actual, tt.expected)
+			}
+		})
+	}
+}
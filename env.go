@@ -1,41 +1,66 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/base64"
-	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/aws/retry"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
-	"github.com/aws/aws-sdk-go-v2/service/ecr"
-	"github.com/aws/aws-sdk-go-v2/service/sts"
 
 	credhelpers "github.com/docker/docker-credential-helpers/credentials"
 )
 
 var (
 	ecrHostname  = regexp.MustCompile(`^(?P<account>[0-9]+)\.dkr\.ecr\.(?P<region>[-a-z0-9]+)\.amazonaws\.com$`)
+	ecrAccountID = regexp.MustCompile(`^[0-9]{12}$`)
 	ghcrHostname = regexp.MustCompile(`^ghcr\.io$`)
+	acrHostname  = regexp.MustCompile(`^(?P<registry>[a-z0-9]+)\.azurecr\.(?P<suffix>io|cn|us)$`)
 )
 
 const (
-	defaultScheme     = "https://"
-	envPrefix         = "DOCKER"
-	envUsernameSuffix = "USR"
-	envPasswordSuffix = "PSW"
-	envSeparator      = "_"
-	envIgnoreLogin    = "IGNORE_DOCKER_LOGIN"
-	envDebugMode      = "DOCKER_CREDENTIAL_ENV_DEBUG"
+	defaultScheme           = "https://"
+	envPrefix               = "DOCKER"
+	envUsernameSuffix       = "USR"
+	envPasswordSuffix       = "PSW"
+	envClientIDSuffix       = "CLIENT_ID"
+	envClientSecretSuffix   = "CLIENT_SECRET" // #nosec G101
+	envTokenURLSuffix       = "TOKEN_URL"
+	envScopeSuffix          = "SCOPE"
+	envTokenSuffix          = "TOKEN"
+	envTokenFileSuffix      = "TOKEN_FILE"
+	envSeparator            = "_"
+	envIgnoreLogin          = "IGNORE_DOCKER_LOGIN"
+	envDebugMode            = "DOCKER_CREDENTIAL_ENV_DEBUG"
+	envStrictEncoding       = "DOCKER_CREDENTIAL_ENV_STRICT_ENCODING"
+	envCaseInsensitive      = "DOCKER_CREDENTIAL_ENV_CASE_INSENSITIVE"
+	envSchemePrefix         = "DOCKER_CREDENTIAL_ENV_PREFIX"
+	envSchemeSuffixes       = "DOCKER_CREDENTIAL_ENV_SUFFIXES"
+	envConfig               = "DOCKER_CREDENTIAL_ENV_CONFIG"
+	envProviders            = "DOCKER_CREDENTIAL_ENV_PROVIDERS"
+	envPlugins              = "DOCKER_CREDENTIAL_ENV_PLUGINS"
+	envPluginTimeout        = "DOCKER_CREDENTIAL_ENV_PLUGIN_TIMEOUT"
+	envDockerConfigPaths    = "DOCKER_CREDENTIAL_ENV_DOCKERCONFIG_PATHS"
+	envNetrc                = "DOCKER_CREDENTIAL_ENV_NETRC"
+	envNetrcPath            = "NETRC"
+	envGitCredential        = "DOCKER_CREDENTIAL_ENV_GIT_CREDENTIAL"
+	envFallback             = "DOCKER_CREDENTIAL_ENV_FALLBACK"
+	envFallbackActive       = "DOCKER_CREDENTIAL_ENV_FALLBACK_ACTIVE"
+	envSession              = "DOCKER_CREDENTIAL_ENV_SESSION"
+	envSessionDir           = "DOCKER_CREDENTIAL_ENV_SESSION_DIR"
+	envSessionTTL           = "DOCKER_CREDENTIAL_ENV_SESSION_TTL"
+	envSecretRefs           = "DOCKER_CREDENTIAL_ENV_SECRET_REFS"
+	envSecretRefExecTimeout = "DOCKER_CREDENTIAL_ENV_EXEC_TIMEOUT"
+	envGitHubToken          = "GITHUB_TOKEN" // #nosec G101
+)
+
+// identityTokenUsername is the username that marks the secret as an identity token in the credential helper protocol.
+const identityTokenUsername = "<token>"
+
+const (
+	envDockerHubUsername = "DOCKERHUB_USERNAME"
+	envDockerHubToken    = "DOCKERHUB_TOKEN" // #nosec G101
 )
 
 const (
@@ -46,6 +71,39 @@ const (
 	envAwsProfile         = "AWS_PROFILE"
 )
 
+const (
+	envAzureClientID              = "AZURE_CLIENT_ID"
+	envAzureTenantID              = "AZURE_TENANT_ID"
+	envAzureClientSecret          = "AZURE_CLIENT_SECRET" // #nosec G101
+	envAzureClientCertificatePath = "AZURE_CLIENT_CERTIFICATE_PATH"
+	envAzureFederatedTokenFile    = "AZURE_FEDERATED_TOKEN_FILE" // #nosec G101
+	envAzureAuthorityHost         = "AZURE_AUTHORITY_HOST"
+)
+
+const (
+	envVaultAddr      = "VAULT_ADDR"
+	envVaultToken     = "VAULT_TOKEN" // #nosec G101
+	envVaultNamespace = "VAULT_NAMESPACE"
+	envVaultPath      = "DOCKER_CREDENTIAL_ENV_VAULT_PATH"
+	envVaultEngine    = "DOCKER_CREDENTIAL_ENV_VAULT_ENGINE"
+	envVaultAuthMount = "DOCKER_CREDENTIAL_ENV_VAULT_AUTH_MOUNT"
+	envVaultRoleID    = "DOCKER_CREDENTIAL_ENV_VAULT_ROLE_ID"
+	envVaultSecretID  = "DOCKER_CREDENTIAL_ENV_VAULT_SECRET_ID" // #nosec G101
+	envVaultJWTRole   = "DOCKER_CREDENTIAL_ENV_VAULT_JWT_ROLE"
+	envVaultJWT       = "DOCKER_CREDENTIAL_ENV_VAULT_JWT"
+	envVaultJWTFile   = "DOCKER_CREDENTIAL_ENV_VAULT_JWT_FILE"
+)
+
+const (
+	envGitLabRegistry                = "CI_REGISTRY"
+	envGitLabRegistryUser            = "CI_REGISTRY_USER"
+	envGitLabRegistryPassword        = "CI_REGISTRY_PASSWORD" // #nosec G101
+	envGitLabJobToken                = "CI_JOB_TOKEN"         // #nosec G101
+	envGitLabDependencyProxyServer   = "CI_DEPENDENCY_PROXY_SERVER"
+	envGitLabDependencyProxyUser     = "CI_DEPENDENCY_PROXY_USER"
+	envGitLabDependencyProxyPassword = "CI_DEPENDENCY_PROXY_PASSWORD" // #nosec G101
+)
+
 // NotSupportedError represents an error indicating that the operation is not supported.
 type NotSupportedError struct{}
 
@@ -57,8 +115,22 @@ func (m *NotSupportedError) Error() string {
 type Env struct{}
 
 // Add implements the set verb.
-func (*Env) Add(*credhelpers.Credentials) error {
+// Credentials are forwarded to the fallback helper or kept in the session store, if either is configured.
+func (*Env) Add(creds *credhelpers.Credentials) error {
+	fallback, err := newFallbackHelper()
+	if err != nil {
+		return fmt.Errorf("add: %w", err)
+	}
+	session, err := newSessionStore()
+	if err != nil {
+		return fmt.Errorf("add: %w", err)
+	}
+
 	switch {
+	case fallback != nil:
+		return fallback.Add(creds)
+	case session != nil:
+		return session.Add(creds)
 	case os.Getenv(envIgnoreLogin) != "":
 		return nil
 	default:
@@ -67,8 +139,22 @@ func (*Env) Add(*credhelpers.Credentials) error {
 }
 
 // Delete implements the erase verb.
-func (*Env) Delete(string) error {
+// Deletion is forwarded to the fallback helper or the session store, if either is configured.
+func (*Env) Delete(serverURL string) error {
+	fallback, err := newFallbackHelper()
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	session, err := newSessionStore()
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
 	switch {
+	case fallback != nil:
+		return fallback.Delete(serverURL)
+	case session != nil:
+		return session.Delete(serverURL)
 	case os.Getenv(envIgnoreLogin) != "":
 		return nil
 	default:
@@ -77,49 +163,44 @@ func (*Env) Delete(string) error {
 }
 
 // List implements the list verb.
+// Only the credentials stored in the fallback helper, if one is configured, can be listed.
 func (*Env) List() (map[string]string, error) {
-	return nil, fmt.Errorf("list: %w", &NotSupportedError{})
+	fallback, err := newFallbackHelper()
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("list: %w", err)
+	case fallback != nil:
+		return fallback.List()
+	default:
+		return nil, fmt.Errorf("list: %w", &NotSupportedError{})
+	}
 }
 
 // Get implements the get verb.
+// Each provider is consulted in the order configured by DOCKER_CREDENTIAL_ENV_PROVIDERS.
 func (e *Env) Get(serverURL string) (username string, password string, err error) {
-	var (
-		hostname string
-		ok       bool
-	)
+	username, password, _, err = resolveServerCredentials(context.Background(), serverURL)
+	return
+}
 
-	hostname, err = getHostname(serverURL)
+// resolveServerCredentials consults the configured providers for the registry at the server URL.
+// Returns the username, password, a boolean indicating if credentials were found, and any error.
+func resolveServerCredentials(ctx context.Context, serverURL string) (username, password string, found bool, err error) {
+	hostname, err := getHostname(serverURL)
 	if err != nil {
-		return
+		return "", "", false, err
 	}
 
-	if username, password, ok = getEnvCredentials(hostname); ok {
-		return
-	}
-
-	submatches := ecrHostname.FindStringSubmatch(hostname)
-	if submatches != nil {
-		envProvider := &ecrContext{
-			AccountID: submatches[ecrHostname.SubexpIndex("account")],
-			Region:    submatches[ecrHostname.SubexpIndex("region")],
-		}
-		username, password, err = getEcrToken(envProvider)
-		return
-	}
-
-	if ghcrHostname.MatchString(hostname) {
-		// This is a GitHub Container Registry: ghcr.io
-		if token, found := os.LookupEnv("GITHUB_TOKEN"); found {
-			username = "x-access-token"
-			password = token
-		}
-		return
+	providers, err := getProviders()
+	if err != nil {
+		return "", "", false, err
 	}
 
-	return
+	return getProviderCredentials(withServerURL(ctx, serverURL), providers, hostname)
 }
 
 // getHostname extracts the hostname from the given server URL, adding a default scheme if missing, and returns it.
+// All Docker Hub aliases are normalised to "docker.io".
 func getHostname(serverURL string) (hostname string, err error) {
 	var server *url.URL
 	server, err = url.Parse(defaultScheme + strings.TrimPrefix(serverURL, defaultScheme))
@@ -128,208 +209,138 @@ func getHostname(serverURL string) (hostname string, err error) {
 	}
 
 	hostname = server.Hostname()
+	if isDockerHub(hostname) {
+		hostname = dockerHubHostname
+	}
 
 	return
 }
 
-// getEnvVariables constructs environment variable names for username and password based on provided labels and offset.
-// Returns the constructed environment variable names for the username and password.
-func getEnvVariables(labels []string, offset int) (envUsername, envPassword string) {
+// getEnvVariable constructs an environment variable name with the given suffix based on provided scheme, labels and offset.
+func getEnvVariable(scheme envScheme, labels []string, offset int, suffix string) string {
 	offset = max(0, min(offset, len(labels)))
 
-	envHostname := strings.Join(labels[offset:], envSeparator)
-	envUsername = strings.Join([]string{envPrefix, envHostname, envUsernameSuffix}, envSeparator)
-	envPassword = strings.Join([]string{envPrefix, envHostname, envPasswordSuffix}, envSeparator)
+	envHostname := scheme.Encoding(labels[offset:])
+	return strings.Join([]string{scheme.Prefix, envHostname, suffix}, envSeparator)
+}
+
+// getEnvVariables constructs environment variable names for each username and password pair, identity token and identity
+// token file based on provided scheme, labels and offset.
+// Returns the constructed environment variable names for the username and password pairs, token and token file.
+func getEnvVariables(scheme envScheme, labels []string, offset int) (envCredentials []envCredentialNames, envToken, envTokenFile string) {
+	for _, suffixes := range scheme.Credentials {
+		envCredentials = append(envCredentials, envCredentialNames{
+			Username: getEnvVariable(scheme, labels, offset, suffixes.Username),
+			Password: getEnvVariable(scheme, labels, offset, suffixes.Password),
+		})
+	}
+	envToken = getEnvVariable(scheme, labels, offset, envTokenSuffix)
+	envTokenFile = getEnvVariable(scheme, labels, offset, envTokenFileSuffix)
 
 	return
 }
 
 // getEnvCredentials retrieves credentials from environment variables based on the provided hostname.
-// It parses the hostname, constructs environment variable names, and checks for corresponding values.
-// Returns the username, password, and a boolean indicating if credentials were found.
-func getEnvCredentials(hostname string) (username, password string, found bool) {
-	hostname = strings.ReplaceAll(hostname, "-", "_")
+// It parses the hostname, constructs environment variable names, and checks for corresponding values,
+// removing DNS labels from the left until a match is found.
+// Returns the username, password, a boolean indicating if credentials were found, and any lookup error.
+func getEnvCredentials(ctx context.Context, hostname string) (username, password string, found bool, err error) {
+	scheme, err := getEnvScheme()
+	if err != nil {
+		return "", "", false, err
+	}
+
 	labels := strings.Split(hostname, ".")
 
 	for i := 0; i <= len(labels); i++ {
-		envUsername, envPassword := getEnvVariables(labels, i)
-
-		if username, found = os.LookupEnv(envUsername); found {
-			if password, found = os.LookupEnv(envPassword); found {
-				break
-			}
+		if username, password, found, err = findEnvCredentials(ctx, scheme, labels, i); found || err != nil {
+			return
 		}
 	}
-	return
+	return "", "", false, nil
 }
 
-// getEcrToken retrieves ECR authentication credentials (username and password) for the specified AWS account and hostname.
-// It uses AWS SDK configuration with a custom retry mechanism (10 attempts max, 5 second max backoff)
-// and a custom credentials provider that checks for account-specific environment variables.
-// The ECR authorization token is retrieved with a 30 second timeout, decoded from base64,
-// and split into username:password format. Debug mode will log token expiration time.
-//
-// Parameters:
-//
-//	account: The AWS account ID
-//	region: The AWS region for the ECR repository
-//
-// Returns:
-//
-//	username: The decoded username (typically "AWS")
-//	password: The decoded password token
-//	err: Any error encountered during the process
-func getEcrToken(provider *ecrContext) (username, password string, err error) {
-	if provider == nil {
-		return "", "", errors.New("ecr: provider must not be nil")
-	}
-
-	// Set up the AWS SDK config with a custom retryer
-	simpleRetryer := func() aws.Retryer {
-		standardRetryer := retry.NewStandard(func(options *retry.StandardOptions) {
-			options.MaxAttempts = 10
-			options.MaxBackoff = time.Second * 5
-		})
-		return retry.AddWithMaxBackoffDelay(standardRetryer, time.Second)
-	}
-
-	var extraOpts []func(*config.LoadOptions) error
-	if provider.HasAccountSuffixedCredentials() { // 1. Account-suffixed credentials
-		// Only use custom provider if account-suffixed access-key credentials exist
-		extraOpts = append(extraOpts, config.WithCredentialsProvider(aws.NewCredentialsCache(provider)))
-	} else if profile := getProfile(provider.AccountID); profile != "" { // 2. Shared config profile
-		// If a profile is specified, use it to load the AWS configuration
-		if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
-			_, _ = fmt.Fprintf(os.Stderr, "AWS profile %q (Account: %s)\n", profile, provider.AccountID)
+// findEnvCredentials checks for credentials for the labels at the given offset with each enabled encoding, in order.
+// In debug mode, a warning is printed if credentials are only found with an ambiguous legacy encoding.
+func findEnvCredentials(ctx context.Context, scheme envScheme, labels []string, offset int) (username, password string, found bool, err error) {
+	encodings := getEnvEncodings()
+	for i, encoding := range encodings {
+		scheme.Encoding = encoding
+		if username, password, found, err = lookupEnvCredentials(ctx, scheme, labels, offset); err != nil {
+			return
+		} else if !found {
+			continue
 		}
-		extraOpts = append(extraOpts, config.WithSharedConfigProfile(profile))
-	}
 
-	// If neither profile nor account-suffixed credentials, use default AWS credential chain
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-	defer cancel()
-	cfg, err := config.LoadDefaultConfig(ctx,
-		append(extraOpts,
-			config.WithRetryer(simpleRetryer),
-			config.WithRegion(provider.Region))...)
-	if err != nil {
-		return username, password, err
-	}
-
-	// If a role ARN is specified for the account, assume that role
-	var roleArn string
-	if roleArn = getRoleArn(provider.AccountID, cfg.ConfigSources...); roleArn != "" {
-		stsSvc := sts.NewFromConfig(cfg)
-		creds := stscreds.NewAssumeRoleProvider(stsSvc, roleArn)
-		cfg.Credentials = aws.NewCredentialsCache(creds)
-	}
-
-	client := ecr.NewFromConfig(cfg)
-
-	output, err := client.GetAuthorizationToken(ctx, nil)
-	if err != nil {
-		return username, password, err
-	}
-	for _, authData := range output.AuthorizationData {
-		if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
-			if authData.ExpiresAt != nil {
-				expiration := authData.ExpiresAt.UTC().Format(time.RFC3339)
-				_, _ = fmt.Fprintf(os.Stderr, "ECR token for %q will expire at %s (UTC)\n", provider.AccountID, expiration)
+		// The legacy encoding is always tried last
+		if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b && i == len(encodings)-1 {
+			offset = max(0, min(offset, len(labels)))
+			if isAmbiguousLegacy(labels[offset:]) {
+				strict := scheme
+				strict.Encoding = strictEncoding
+				_, _ = fmt.Fprintf(os.Stderr, "Warning: %q matched ambiguous variables %s*; consider %s* with %s=true\n",
+					strings.Join(labels, "."), getEnvVariable(scheme, labels, offset, ""),
+					getEnvVariable(strict, labels, offset, ""), envStrictEncoding)
 			}
 		}
-
-		if authData.AuthorizationToken == nil {
-			err = fmt.Errorf("ecr: authorization token for %q is nil", provider.AccountID)
-			return username, password, err
-		}
-
-		var tokenBytes []byte
-		tokenBytes, err = base64.StdEncoding.DecodeString(*authData.AuthorizationToken)
-		if err != nil {
-			return username, password, err
-		}
-		token := bytes.SplitN(tokenBytes, []byte{':'}, 2)
-		if len(token) != 2 {
-			err = fmt.Errorf("ecr: invalid authorization token format for %q", provider.AccountID)
-			return username, password, err
-		}
-
-		username, password = string(token[0]), string(token[1])
+		return
 	}
-	return username, password, err
+	return "", "", false, nil
 }
 
-// getProfile resolves an AWS profile name by checking, in order:
-// 1. Account-specific environment variable (AWS_PROFILE_<account>)
-// 2. Configuration sources (SharedConfigProfile or Profile)
-// 3. Default AWS_PROFILE environment variable
-//
-// Parameters:
-//
-//	account - AWS account ID to look up account-specific profile name
-//	configSources - Optional AWS configuration sources containing profile information
-//
-// Returns:
+// lookupEnvCredentials checks for credentials in the environment variables for the encoded labels at the given offset.
+// The variables are checked in order of precedence:
+// 1. _USR and _PSW (or each configured pair of suffixes, in order): static username and password
+// 2. _TOKEN or _TOKEN_FILE: identity token, returned with the username "<token>"
+// 3. _CLIENT_ID, _CLIENT_SECRET and _TOKEN_URL: OAuth2 client credentials, exchanged for an access token
 //
-//	profile - Resolved AWS profile name, empty string if none found
-func getProfile(account string, configSources ...any) (profile string) {
-	// Check for account-specific profile environment variable
-	val, found := os.LookupEnv(envAwsProfile + "_" + account)
-	if found {
-		return strings.TrimSpace(val)
+// Username, password and token values may be secret references.
+// Returns the username, password, a boolean indicating if credentials were found, and any lookup error.
+func lookupEnvCredentials(ctx context.Context, scheme envScheme, labels []string, offset int) (username, password string, found bool, err error) {
+	envCredentials, envToken, envTokenFile := getEnvVariables(scheme, labels, offset)
+
+	for _, envCredential := range envCredentials {
+		if username, found = lookupEnvVariable(envCredential.Username); found {
+			if password, found = lookupEnvVariable(envCredential.Password); found {
+				username, password, err = resolveCredentials(ctx, username, password)
+				return username, password, err == nil, err
+			}
+		}
 	}
 
-	if len(configSources) == 0 {
-		return os.Getenv("AWS_PROFILE")
+	if token, found := lookupEnvVariable(envToken); found {
+		if token, err = resolveSecretRef(ctx, token); err != nil {
+			return "", "", false, err
+		}
+		return identityTokenUsername, token, true, nil
 	}
 
-	for _, x := range configSources {
-		switch impl := x.(type) {
-		case config.EnvConfig:
-			if impl.SharedConfigProfile != "" {
-				return strings.TrimSpace(impl.SharedConfigProfile)
-			}
-		case config.SharedConfig:
-			if impl.Profile != "" {
-				return strings.TrimSpace(impl.Profile)
-			}
+	if tokenFile, found := lookupEnvVariable(envTokenFile); found {
+		token, err := readSecretFile(tokenFile)
+		if err != nil {
+			return "", "", false, err
 		}
+		return identityTokenUsername, token, true, nil
 	}
-	return
-}
 
-// getRoleArn retrieves the AWS role ARN for a specific account by checking environment variables and AWS configurations.
-// It checks the account-specific role ARN environment variable (AWS_ROLE_ARN_<account>). If not found,
-// then checks the standard AWS role ARN environment variable (AWS_ROLE_ARN) when no config sources are provided.
-// Finally, checks config sources which may contain role ARNs in AWS environment config or shared config.
-// Returns role ARN string if found, empty string otherwise.
-func getRoleArn(account string, configSources ...any) (roleARN string) {
-	val, found := os.LookupEnv(envAwsRoleArn + "_" + account)
-	if found {
-		return strings.TrimSpace(val)
+	if client := getOAuth2Client(scheme, labels, offset); client != nil {
+		username, password, err = client.Credentials(ctx)
+		return username, password, err == nil, err
 	}
 
-	// Check if any account-specific AWS credentials exist
-	_, hasSuffixedEnv := os.LookupEnv(envAwsAccessKeyID + "_" + account)
-	if hasSuffixedEnv {
-		return ""
-	}
+	return "", "", false, nil
+}
 
-	if len(configSources) == 0 {
-		return os.Getenv(envAwsRoleArn)
-	}
+// envProvider provides credentials from DOCKER_<hostname>_* environment variables.
+type envProvider struct{}
 
-	for _, x := range configSources {
-		switch impl := x.(type) {
-		case config.EnvConfig:
-			if impl.RoleARN != "" {
-				return strings.TrimSpace(impl.RoleARN)
-			}
-		case config.SharedConfig:
-			if impl.RoleARN != "" {
-				return strings.TrimSpace(impl.RoleARN)
-			}
-		}
-	}
-	return
+// Match implements Provider.
+func (*envProvider) Match(string) bool { return true }
+
+// Get implements Provider.
+func (*envProvider) Get(ctx context.Context, host string) (username, password string, found bool, err error) {
+	return getEnvCredentials(ctx, host)
 }
+
+// Describe implements Provider.
+func (*envProvider) Describe() string { return "DOCKER_<hostname>_* environment variables" }
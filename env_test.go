@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"path/filepath"
+	"strconv"
 	"testing"
 )
 
@@ -41,6 +44,16 @@ func TestGetHostname(t *testing.T) {
 			input:    "https://example-hyphen.com/path",
 			expected: "example-hyphen.com",
 		},
+		{
+			name:     "Docker Hub index",
+			input:    "https://index.docker.io/v1/",
+			expected: "docker.io",
+		},
+		{
+			name:     "Docker Hub registry",
+			input:    "registry-1.docker.io",
+			expected: "docker.io",
+		},
 	}
 
 	for _, tt := range tests {
@@ -60,11 +73,14 @@ func TestGetEnvVariables(t *testing.T) {
 	type args struct {
 		labels []string
 		offset int
+		strict bool
 	}
 
 	type output struct {
-		envUsername string
-		envPassword string
+		envUsername  string
+		envPassword  string
+		envToken     string
+		envTokenFile string
 	}
 
 	tests := []struct {
@@ -75,40 +91,73 @@ func TestGetEnvVariables(t *testing.T) {
 		{
 			name:     "Negative Offset",
 			input:    args{labels: []string{"repo", "example", "com"}, offset: -1},
-			expected: output{envUsername: "DOCKER_repo_example_com_USR", envPassword: "DOCKER_repo_example_com_PSW"},
+			expected: output{envUsername: "DOCKER_repo_example_com_USR", envPassword: "DOCKER_repo_example_com_PSW", envToken: "DOCKER_repo_example_com_TOKEN", envTokenFile: "DOCKER_repo_example_com_TOKEN_FILE"},
 		},
 		{
 			name:     "Offset 0",
 			input:    args{labels: []string{"repo", "example", "com"}, offset: 0},
-			expected: output{envUsername: "DOCKER_repo_example_com_USR", envPassword: "DOCKER_repo_example_com_PSW"},
+			expected: output{envUsername: "DOCKER_repo_example_com_USR", envPassword: "DOCKER_repo_example_com_PSW", envToken: "DOCKER_repo_example_com_TOKEN", envTokenFile: "DOCKER_repo_example_com_TOKEN_FILE"},
 		},
 		{
 			name:     "Offset 1",
 			input:    args{labels: []string{"repo", "example", "com"}, offset: 1},
-			expected: output{envUsername: "DOCKER_example_com_USR", envPassword: "DOCKER_example_com_PSW"},
+			expected: output{envUsername: "DOCKER_example_com_USR", envPassword: "DOCKER_example_com_PSW", envToken: "DOCKER_example_com_TOKEN", envTokenFile: "DOCKER_example_com_TOKEN_FILE"},
 		},
 		{
 			name:     "Offset 2",
 			input:    args{labels: []string{"repo", "example", "com"}, offset: 2},
-			expected: output{envUsername: "DOCKER_com_USR", envPassword: "DOCKER_com_PSW"},
+			expected: output{envUsername: "DOCKER_com_USR", envPassword: "DOCKER_com_PSW", envToken: "DOCKER_com_TOKEN", envTokenFile: "DOCKER_com_TOKEN_FILE"},
 		},
 		{
 			name:     "Fallback",
 			input:    args{labels: []string{"repo", "example", "com"}, offset: 3},
-			expected: output{envUsername: "DOCKER__USR", envPassword: "DOCKER__PSW"},
+			expected: output{envUsername: "DOCKER__USR", envPassword: "DOCKER__PSW", envToken: "DOCKER__TOKEN", envTokenFile: "DOCKER__TOKEN_FILE"},
 		},
 		{
 			name:     "Overflow Offset",
 			input:    args{labels: []string{"repo", "example", "com"}, offset: 4},
-			expected: output{envUsername: "DOCKER__USR", envPassword: "DOCKER__PSW"},
+			expected: output{envUsername: "DOCKER__USR", envPassword: "DOCKER__PSW", envToken: "DOCKER__TOKEN", envTokenFile: "DOCKER__TOKEN_FILE"},
+		},
+		{
+			name:     "Legacy hyphen",
+			input:    args{labels: []string{"my-reg", "example", "com"}, offset: 0},
+			expected: output{envUsername: "DOCKER_my_reg_example_com_USR", envPassword: "DOCKER_my_reg_example_com_PSW", envToken: "DOCKER_my_reg_example_com_TOKEN", envTokenFile: "DOCKER_my_reg_example_com_TOKEN_FILE"},
+		},
+		{
+			name:     "Strict",
+			input:    args{labels: []string{"repo", "example", "com"}, offset: 0, strict: true},
+			expected: output{envUsername: "DOCKER_repo__example__com_USR", envPassword: "DOCKER_repo__example__com_PSW", envToken: "DOCKER_repo__example__com_TOKEN", envTokenFile: "DOCKER_repo__example__com_TOKEN_FILE"},
+		},
+		{
+			name:     "Strict hyphen",
+			input:    args{labels: []string{"my-reg", "example", "com"}, offset: 0, strict: true},
+			expected: output{envUsername: "DOCKER_my_2D_reg__example__com_USR", envPassword: "DOCKER_my_2D_reg__example__com_PSW", envToken: "DOCKER_my_2D_reg__example__com_TOKEN", envTokenFile: "DOCKER_my_2D_reg__example__com_TOKEN_FILE"},
+		},
+		{
+			name:     "Strict underscore with offset",
+			input:    args{labels: []string{"repo", "my_reg", "com"}, offset: 1, strict: true},
+			expected: output{envUsername: "DOCKER_my_5F_reg__com_USR", envPassword: "DOCKER_my_5F_reg__com_PSW", envToken: "DOCKER_my_5F_reg__com_TOKEN", envTokenFile: "DOCKER_my_5F_reg__com_TOKEN_FILE"},
+		},
+		{
+			name:     "Strict IPv6",
+			input:    args{labels: []string{"fd00::1"}, offset: 0, strict: true},
+			expected: output{envUsername: "DOCKER_fd00_3A__3A_1_USR", envPassword: "DOCKER_fd00_3A__3A_1_PSW", envToken: "DOCKER_fd00_3A__3A_1_TOKEN", envTokenFile: "DOCKER_fd00_3A__3A_1_TOKEN_FILE"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actualEnvUsername, actualEnvPassword := getEnvVariables(tt.input.labels, tt.input.offset)
-			if actualEnvUsername != tt.expected.envUsername || actualEnvPassword != tt.expected.envPassword {
-				t.Errorf("Get(%v) actual = (%v, %v), expected (%v, %v)", tt.input, actualEnvUsername, actualEnvPassword, tt.expected.envUsername, tt.expected.envPassword)
+			scheme := defaultEnvScheme
+			if tt.input.strict {
+				scheme.Encoding = strictEncoding
+			}
+			actualEnvCredentials, actualEnvToken, actualEnvTokenFile := getEnvVariables(scheme, tt.input.labels, tt.input.offset)
+			if len(actualEnvCredentials) != 1 {
+				t.Fatalf("Get(%v) actual = %d username and password pairs, expected 1", tt.input, len(actualEnvCredentials))
+			}
+			actual := output{envUsername: actualEnvCredentials[0].Username, envPassword: actualEnvCredentials[0].Password, envToken: actualEnvToken, envTokenFile: actualEnvTokenFile}
+			if actual != tt.expected {
+				t.Errorf("Get(%v) actual = (%+v), expected (%+v)", tt.input, actual, tt.expected)
 			}
 		})
 	}
@@ -154,7 +203,185 @@ func TestGetEnvCredentials(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actualUsername, actualPassword, actualFound := getEnvCredentials(tt.input)
+			actualUsername, actualPassword, actualFound, actualErr := getEnvCredentials(context.Background(), tt.input)
+			if actualErr != nil {
+				t.Errorf("getEnvCredentials(%v) unexpected error: %v", tt.input, actualErr)
+			}
+			if actualUsername != tt.expected.username || actualPassword != tt.expected.password || actualFound != tt.expected.found {
+				t.Errorf("getEnvCredentials(%v) actual = (%v, %v, %v), expected (%v, %v, %v)", tt.input, actualUsername, actualPassword, actualFound, tt.expected.username, tt.expected.password, tt.expected.found)
+			}
+		})
+	}
+}
+
+func TestGetEnvCredentials_Token(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	writeFile(t, tokenFile, "t2\n")
+
+	type output struct {
+		username string
+		password string
+		found    bool
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected output
+	}{
+		{
+			name:     "Token",
+			input:    "token.example.com",
+			expected: output{username: "<token>", password: "t1", found: true},
+		},
+		{
+			name:     "Token file",
+			input:    "file.example.com",
+			expected: output{username: "<token>", password: "t2", found: true},
+		},
+		{
+			name:     "Token has higher priority than token file",
+			input:    "both.example.com",
+			expected: output{username: "<token>", password: "t1", found: true},
+		},
+		{
+			name:     "Username and password have higher priority than token",
+			input:    "static.example.com",
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name:     "More specific token has higher priority than less specific username and password",
+			input:    "token.static.example.com",
+			expected: output{username: "<token>", password: "t3", found: true},
+		},
+	}
+
+	t.Setenv("DOCKER_token_example_com_TOKEN", "t1")
+	t.Setenv("DOCKER_file_example_com_TOKEN_FILE", tokenFile)
+	t.Setenv("DOCKER_both_example_com_TOKEN", "t1")
+	t.Setenv("DOCKER_both_example_com_TOKEN_FILE", tokenFile)
+	t.Setenv("DOCKER_static_example_com_USR", "u1")
+	t.Setenv("DOCKER_static_example_com_PSW", "p1")
+	t.Setenv("DOCKER_static_example_com_TOKEN", "t0")
+	t.Setenv("DOCKER_token_static_example_com_TOKEN", "t3")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actualUsername, actualPassword, actualFound, actualErr := getEnvCredentials(context.Background(), tt.input)
+			if actualErr != nil {
+				t.Errorf("getEnvCredentials(%v) unexpected error: %v", tt.input, actualErr)
+			}
+			if actualUsername != tt.expected.username || actualPassword != tt.expected.password || actualFound != tt.expected.found {
+				t.Errorf("getEnvCredentials(%v) actual = (%v, %v, %v), expected (%v, %v, %v)", tt.input, actualUsername, actualPassword, actualFound, tt.expected.username, tt.expected.password, tt.expected.found)
+			}
+		})
+	}
+
+	t.Run("Missing token file", func(t *testing.T) {
+		t.Setenv("DOCKER_missing_example_com_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+		if _, _, _, err := getEnvCredentials(context.Background(), "missing.example.com"); err == nil {
+			t.Error("expected an error but got none")
+		}
+	})
+}
+
+func TestGetEnvCredentials_StrictEncoding(t *testing.T) {
+	type output struct {
+		username string
+		password string
+		found    bool
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		strict   bool
+		expected output
+	}{
+		{
+			name:     "Legacy hyphen collides with underscore",
+			input:    "my-reg.example.com",
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name:     "Strict hyphen",
+			input:    "my-reg.example.com",
+			strict:   true,
+			expected: output{username: "u2", password: "p2", found: true},
+		},
+		{
+			name:     "Strict underscore",
+			input:    "my_reg.example.com",
+			strict:   true,
+			expected: output{username: "u3", password: "p3", found: true},
+		},
+		{
+			name:     "Legacy dot collides with underscore",
+			input:    "a_b.c.com",
+			expected: output{username: "u4", password: "p4", found: true},
+		},
+		{
+			name:     "Strict dot and underscore",
+			input:    "a_b.c.com",
+			strict:   true,
+			expected: output{username: "u5", password: "p5", found: true},
+		},
+		{
+			name:     "Strict falls back to legacy",
+			input:    "a.b_c.com",
+			strict:   true,
+			expected: output{username: "u4", password: "p4", found: true},
+		},
+		{
+			name:     "Strict IPv6",
+			input:    "fd00::1",
+			strict:   true,
+			expected: output{username: "u6", password: "p6", found: true},
+		},
+		{
+			name:     "Strict subdomain",
+			input:    "repo.my-reg.example.com",
+			strict:   true,
+			expected: output{username: "u2", password: "p2", found: true},
+		},
+		{
+			name:     "Strict uppercase",
+			input:    "upper-reg.example.com",
+			strict:   true,
+			expected: output{username: "u7", password: "p7", found: true},
+		},
+		{
+			name:     "Legacy uppercase",
+			input:    "upper-reg.example.com",
+			expected: output{username: "u8", password: "p8", found: true},
+		},
+	}
+
+	t.Setenv("DOCKER_my_reg_example_com_USR", "u1")
+	t.Setenv("DOCKER_my_reg_example_com_PSW", "p1")
+	t.Setenv("DOCKER_my_2D_reg__example__com_USR", "u2")
+	t.Setenv("DOCKER_my_2D_reg__example__com_PSW", "p2")
+	t.Setenv("DOCKER_my_5F_reg__example__com_USR", "u3")
+	t.Setenv("DOCKER_my_5F_reg__example__com_PSW", "p3")
+	t.Setenv("DOCKER_a_b_c_com_USR", "u4")
+	t.Setenv("DOCKER_a_b_c_com_PSW", "p4")
+	t.Setenv("DOCKER_a_5F_b__c__com_USR", "u5")
+	t.Setenv("DOCKER_a_5F_b__c__com_PSW", "p5")
+	t.Setenv("DOCKER_fd00_3A__3A_1_USR", "u6")
+	t.Setenv("DOCKER_fd00_3A__3A_1_PSW", "p6")
+	t.Setenv("DOCKER_UPPER_2D_REG__EXAMPLE__COM_USR", "u7")
+	t.Setenv("DOCKER_UPPER_2D_REG__EXAMPLE__COM_PSW", "p7")
+	t.Setenv("DOCKER_UPPER_REG_EXAMPLE_COM_USR", "u8")
+	t.Setenv("DOCKER_UPPER_REG_EXAMPLE_COM_PSW", "p8")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DOCKER_CREDENTIAL_ENV_STRICT_ENCODING", strconv.FormatBool(tt.strict))
+
+			actualUsername, actualPassword, actualFound, actualErr := getEnvCredentials(context.Background(), tt.input)
+			if actualErr != nil {
+				t.Errorf("getEnvCredentials(%v) unexpected error: %v", tt.input, actualErr)
+			}
 			if actualUsername != tt.expected.username || actualPassword != tt.expected.password || actualFound != tt.expected.found {
 				t.Errorf("getEnvCredentials(%v) actual = (%v, %v, %v), expected (%v, %v, %v)", tt.input, actualUsername, actualPassword, actualFound, tt.expected.username, tt.expected.password, tt.expected.found)
 			}
@@ -269,98 +496,3 @@ func TestEnvNotSupportedMethods(t *testing.T) {
 		}
 	})
 }
-
-func TestGetRoleArn(t *testing.T) {
-	tests := []struct {
-		name     string
-		inputEnv map[string]string
-		expected string
-	}{
-		{
-			name: "Standard environment variables",
-			inputEnv: map[string]string{
-				"AWS_ROLE_ARN": "arn:aws:iam::123456789012:role/my-role",
-			},
-			expected: "arn:aws:iam::123456789012:role/my-role",
-		},
-		{
-			name: "Suffixed environment variables",
-			inputEnv: map[string]string{
-				"AWS_ROLE_ARN_123456789012": "arn:aws:iam::123456789012:role/my-role",
-			},
-			expected: "arn:aws:iam::123456789012:role/my-role",
-		},
-		{
-			name: "Suffixed has higher priority",
-			inputEnv: map[string]string{
-				"AWS_ROLE_ARN":              "arn:aws:iam::123456789012:role/other-role",
-				"AWS_ROLE_ARN_123456789012": "arn:aws:iam::123456789012:role/my-role",
-			},
-			expected: "arn:aws:iam::123456789012:role/my-role",
-		},
-		{
-			name: "Suffixed variables set but role ARN set for standard environment",
-			inputEnv: map[string]string{
-				"AWS_ROLE_ARN":                       "arn:aws:iam::123456789012:role/my-role",
-				"AWS_ACCESS_KEY_ID_123456789012":     "AKIA...",
-				"AWS_SECRET_ACCESS_KEY_123456789012": "wJalr...",
-			},
-			expected: "",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			for k, v := range tt.inputEnv {
-				t.Setenv(k, v)
-			}
-			actual := getRoleArn("123456789012")
-			if actual != tt.expected {
-				t.Errorf("GetRoleArn(<account_id>) actual = (%v), expected (%v)", actual, tt.expected)
-			}
-		})
-	}
-}
-
-func TestGetProfile(t *testing.T) {
-	tests := []struct {
-		name     string
-		inputEnv map[string]string
-		expected string
-	}{
-		{
-			name: "Standard environment variable",
-			inputEnv: map[string]string{
-				"AWS_PROFILE": "my-profile",
-			},
-			expected: "my-profile",
-		},
-		{
-			name: "Suffixed environment variable",
-			inputEnv: map[string]string{
-				"AWS_PROFILE_12345": "my-profile",
-			},
-			expected: "my-profile",
-		},
-		{
-			name: "Suffixed has higher priority",
-			inputEnv: map[string]string{
-				"AWS_PROFILE":       "other-profile",
-				"AWS_PROFILE_12345": "my-profile",
-			},
-			expected: "my-profile",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			for k, v := range tt.inputEnv {
-				t.Setenv(k, v)
-			}
-			actual := getProfile("12345")
-			if actual != tt.expected {
-				t.Errorf("GetProfile(<suffix>) actual = (%v), expected (%v)", actual, tt.expected)
-			}
-		})
-	}
-}
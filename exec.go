@@ -0,0 +1,194 @@
+// Package main provides the exec command, which runs a command with an isolated Docker client configuration.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// execAuthKeys are the Docker config keys that are replaced in the isolated configuration.
+var execAuthKeys = []string{"auths", "credsStore", "credHelpers"}
+
+// execSharedDirs are the Docker config subdirectories, holding CLI plugins, contexts and builder state,
+// that are linked into the isolated configuration directory.
+var execSharedDirs = []string{"cli-plugins", "contexts", "buildx"}
+
+// execSignals are forwarded to the child process.
+var execSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// execCmd handles the logic for the "exec" command.
+type execCmd struct {
+	Registries []string
+	Command    []string
+	Stdin      io.Reader
+	Stdout     io.Writer
+	Stderr     io.Writer
+	configPath string
+}
+
+// Run runs the command with DOCKER_CONFIG pointing to a temporary directory, which is removed afterwards.
+// Returns the exit code of the command.
+func (c *execCmd) Run() (int, error) {
+	configDir, err := os.MkdirTemp("", "docker-credential-env-exec-")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create Docker config directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(configDir) }()
+
+	if err := c.writeConfig(configDir); err != nil {
+		return 0, err
+	}
+
+	if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+		_, _ = fmt.Fprintf(os.Stderr, "Running %q with DOCKER_CONFIG=%s\n", c.Command[0], configDir)
+	}
+
+	cmd := exec.Command(c.Command[0], c.Command[1:]...) // #nosec G204 -- command is supplied by the operator
+	cmd.Stdin = c.Stdin
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+	cmd.Env = append(withoutEnv(os.Environ(), "DOCKER_CONFIG"), "DOCKER_CONFIG="+configDir)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, execSignals...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to run %q: %w", c.Command[0], err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				_ = cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return 0, fmt.Errorf("failed to run %q: %w", c.Command[0], err)
+		}
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal()), nil
+		}
+		return exitErr.ExitCode(), nil
+	}
+	return 0, nil
+}
+
+// writeConfig writes the isolated config.json to configDir: the settings of the user's configuration,
+// such as proxies and plugins, with its credentials replaced by the env credential helper.
+func (c *execCmd) writeConfig(configDir string) error {
+	config := map[string]json.RawMessage{}
+	configData, err := os.ReadFile(c.configPath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("failed to read Docker config file %q: %w", c.configPath, err)
+	default:
+		if err := json.Unmarshal(configData, &config); err != nil {
+			return fmt.Errorf("failed to parse Docker config file %q: %w", c.configPath, err)
+		}
+	}
+
+	for _, key := range execAuthKeys {
+		delete(config, key)
+	}
+	if len(c.Registries) == 0 {
+		config["credsStore"] = json.RawMessage(`"env"`)
+	} else {
+		credHelpers := make(map[string]string, len(c.Registries))
+		for _, registry := range c.Registries {
+			credHelpers[registry] = "env"
+		}
+		if config["credHelpers"], err = json.Marshal(credHelpers); err != nil {
+			return fmt.Errorf("failed to marshal Docker config: %w", err)
+		}
+	}
+
+	configData, err = json.MarshalIndent(config, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to marshal Docker config: %w", err)
+	}
+	isolatedPath := filepath.Join(configDir, "config.json")
+	if err = os.WriteFile(isolatedPath, configData, 0600); err != nil {
+		return fmt.Errorf("failed to write Docker config file %q: %w", isolatedPath, err)
+	}
+
+	for _, name := range execSharedDirs {
+		sharedDir := filepath.Join(filepath.Dir(c.configPath), name)
+		if info, err := os.Stat(sharedDir); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := os.Symlink(sharedDir, filepath.Join(configDir, name)); err != nil {
+			return fmt.Errorf("failed to link Docker config directory %q: %w", sharedDir, err)
+		}
+	}
+	return nil
+}
+
+// RunExecCommand is the main entry point for the exec command.
+// Returns the exit code of the command.
+func RunExecCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	const usage = "Usage: docker-credential-env exec [--registry <registry>]... [--] <command> [args...]"
+
+	cmd := &execCmd{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
+	}
+
+parse:
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--":
+			cmd.Command = args[i+1:]
+			break parse
+		case arg == "--registry" || arg == "-r":
+			if i+1 == len(args) {
+				return 0, fmt.Errorf("%q requires a registry\n%s", arg, usage)
+			}
+			i++
+			cmd.Registries = append(cmd.Registries, args[i])
+		case strings.HasPrefix(arg, "--registry="):
+			cmd.Registries = append(cmd.Registries, strings.TrimPrefix(arg, "--registry="))
+		case strings.HasPrefix(arg, "-"):
+			return 0, fmt.Errorf("unknown flag %q\n%s", arg, usage)
+		default:
+			cmd.Command = args[i:]
+			break parse
+		}
+	}
+
+	if len(cmd.Command) == 0 {
+		return 0, fmt.Errorf("missing command\n%s", usage)
+	}
+	for _, registry := range cmd.Registries {
+		if err := validateRegistry(registry); err != nil {
+			return 0, err
+		}
+	}
+
+	var err error
+	if cmd.configPath, err = getDockerConfigPath(); err != nil {
+		return 0, err
+	}
+
+	return cmd.Run()
+}
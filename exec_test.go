@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunExecCommand_Errors(t *testing.T) {
+	setupTestEnvironment(t)
+
+	testCases := []struct {
+		name        string
+		args        []string
+		errContains string
+	}{
+		{"no args", []string{}, "missing command"},
+		{"no command after separator", []string{"--registry", "ghcr.io", "--"}, "missing command"},
+		{"missing registry", []string{"--registry"}, `"--registry" requires a registry`},
+		{"invalid registry", []string{"--registry=ghcr.io/org", "true"}, "invalid registry"},
+		{"unknown flag", []string{"--verbose", "true"}, `unknown flag "--verbose"`},
+		{"command not found", []string{"--", "docker-credential-env-test-missing"}, "failed to run"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := RunExecCommand(tc.args, nil, new(bytes.Buffer), new(bytes.Buffer))
+			if err == nil {
+				t.Fatalf("Expected an error but got none")
+			}
+			if !strings.Contains(err.Error(), tc.errContains) {
+				t.Errorf("Expected error to contain %q, but got %q", tc.errContains, err.Error())
+			}
+		})
+	}
+}
+
+func TestRunExecCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command requires a POSIX shell")
+	}
+
+	tempDir := setupTestEnvironment(t)
+	writeFile(t, filepath.Join(tempDir, "config.json"), `{
+	"auths": {"registry.example.com": {"auth": "dXNlcjpwYXNz"}},
+	"credsStore": "desktop",
+	"credHelpers": {"gcr.io": "gcloud"},
+	"proxies": {"default": {"httpProxy": "http://proxy.example.com:3128"}},
+	"psFormat": "table {{.ID}}"
+}`)
+	if err := os.Mkdir(filepath.Join(tempDir, "cli-plugins"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	script := `echo "$DOCKER_CONFIG" >&2; cat "$DOCKER_CONFIG/config.json"; test -d "$DOCKER_CONFIG/cli-plugins" || exit 9; exit 3`
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected map[string]any
+	}{
+		{
+			name: "Default credential store",
+			args: []string{"--", "sh", "-c", script},
+			expected: map[string]any{
+				"credsStore": "env",
+				"proxies":    map[string]any{"default": map[string]any{"httpProxy": "http://proxy.example.com:3128"}},
+				"psFormat":   "table {{.ID}}",
+			},
+		},
+		{
+			name: "Registry credential helpers",
+			args: []string{"--registry", "ghcr.io", "-r", "docker.io", "sh", "-c", script},
+			expected: map[string]any{
+				"credHelpers": map[string]any{"ghcr.io": "env", "docker.io": "env"},
+				"proxies":     map[string]any{"default": map[string]any{"httpProxy": "http://proxy.example.com:3128"}},
+				"psFormat":    "table {{.ID}}",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+			code, err := RunExecCommand(tt.args, nil, stdout, stderr)
+			if err != nil {
+				t.Fatalf("RunExecCommand() unexpected error: %v", err)
+			}
+			if code != 3 {
+				t.Errorf("RunExecCommand() exit code actual = (%v), expected (3)", code)
+			}
+
+			var actual map[string]any
+			if err := json.Unmarshal(stdout.Bytes(), &actual); err != nil {
+				t.Fatalf("Failed to parse isolated config %q: %v", stdout.String(), err)
+			}
+			actualJSON, _ := json.Marshal(actual)
+			expectedJSON, _ := json.Marshal(tt.expected)
+			if !bytes.Equal(actualJSON, expectedJSON) {
+				t.Errorf("RunExecCommand() config actual = (%s), expected (%s)", actualJSON, expectedJSON)
+			}
+
+			configDir := strings.TrimSpace(stderr.String())
+			if configDir == "" || configDir == tempDir {
+				t.Fatalf("RunExecCommand() DOCKER_CONFIG actual = (%v), expected a temporary directory", configDir)
+			}
+			if _, err := os.Stat(configDir); !os.IsNotExist(err) {
+				t.Errorf("Expected %q to be removed, but got %v", configDir, err)
+			}
+		})
+	}
+
+	t.Run("Original config is unchanged", func(t *testing.T) {
+		configData, err := os.ReadFile(filepath.Join(tempDir, "config.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(configData), `"credsStore": "desktop"`) {
+			t.Errorf("Expected original config to be unchanged, but got %s", configData)
+		}
+	})
+}
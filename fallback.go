@@ -0,0 +1,145 @@
+// Package main provides delegation to a fallback Docker credential helper.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/docker/docker-credential-helpers/client"
+	credhelpers "github.com/docker/docker-credential-helpers/credentials"
+)
+
+// helperPrefix is the executable name prefix shared by all Docker credential helpers.
+const helperPrefix = "docker-credential-"
+
+// LoopError represents an error indicating that the fallback helper delegates back to this helper.
+type LoopError struct {
+	Helper string
+}
+
+func (m *LoopError) Error() string {
+	return fmt.Sprintf("fallback loop detected via %q", helperPrefix+m.Helper)
+}
+
+// fallbackHelper delegates credential operations to another Docker credential helper,
+// configured with DOCKER_CREDENTIAL_ENV_FALLBACK=<helper-name> (e.g. "pass", "secretservice").
+type fallbackHelper struct {
+	Name string
+
+	program client.ProgramFunc
+}
+
+// newFallbackHelper returns the configured fallback helper, or nil if none is configured.
+// Returns a LoopError if the fallback resolves to this helper, or if this process was itself
+// invoked as a fallback, which would otherwise delegate forever.
+func newFallbackHelper() (*fallbackHelper, error) {
+	name := os.Getenv(envFallback)
+	if name == "" {
+		return nil, nil
+	}
+
+	if name == "env" {
+		return nil, &LoopError{Helper: name}
+	}
+	if active, err := strconv.ParseBool(os.Getenv(envFallbackActive)); err == nil && active {
+		return nil, &LoopError{Helper: name}
+	}
+	if isSelf(helperPrefix + name) {
+		return nil, &LoopError{Helper: name}
+	}
+
+	env := map[string]string{envFallbackActive: "true"}
+	return &fallbackHelper{
+		Name:    name,
+		program: client.NewShellProgramFuncWithEnv(helperPrefix+name, &env),
+	}, nil
+}
+
+// isSelf reports whether the named executable on PATH resolves to the running executable.
+func isSelf(command string) bool {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return false
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return false
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	self, err = filepath.EvalSymlinks(self)
+	if err != nil {
+		return false
+	}
+	return path == self
+}
+
+// Get retrieves credentials from the fallback helper. Credentials that are not found are not an error.
+func (f *fallbackHelper) Get(serverURL string) (username, password string, found bool, err error) {
+	creds, err := client.Get(f.program, serverURL)
+	if credhelpers.IsErrCredentialsNotFound(err) {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, fmt.Errorf("fallback %q: %w", f.Name, err)
+	}
+
+	if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+		_, _ = fmt.Fprintf(os.Stderr, "Authenticating access to %q with %q credential helper\n", serverURL, helperPrefix+f.Name)
+	}
+
+	return creds.Username, creds.Secret, true, nil
+}
+
+// Add stores credentials in the fallback helper.
+func (f *fallbackHelper) Add(creds *credhelpers.Credentials) error {
+	if creds == nil {
+		return errors.New("fallback: credentials must not be nil")
+	}
+	if err := client.Store(f.program, creds); err != nil {
+		return fmt.Errorf("fallback %q: %w", f.Name, err)
+	}
+	return nil
+}
+
+// Delete removes credentials from the fallback helper.
+func (f *fallbackHelper) Delete(serverURL string) error {
+	if err := client.Erase(f.program, serverURL); err != nil {
+		return fmt.Errorf("fallback %q: %w", f.Name, err)
+	}
+	return nil
+}
+
+// List lists the credentials stored in the fallback helper.
+func (f *fallbackHelper) List() (map[string]string, error) {
+	accounts, err := client.List(f.program)
+	if err != nil {
+		return nil, fmt.Errorf("fallback %q: %w", f.Name, err)
+	}
+	return accounts, nil
+}
+
+// fallbackProvider delegates to the fallback credential helper, if configured.
+type fallbackProvider struct{}
+
+// Match implements Provider.
+func (*fallbackProvider) Match(string) bool { return os.Getenv(envFallback) != "" }
+
+// Get implements Provider.
+func (*fallbackProvider) Get(ctx context.Context, host string) (username, password string, found bool, err error) {
+	fallback, err := newFallbackHelper()
+	if err != nil || fallback == nil {
+		return "", "", false, err
+	}
+	return fallback.Get(serverURLFromContext(ctx, host))
+}
+
+// Describe implements Provider.
+func (*fallbackProvider) Describe() string { return "fallback credential helper" }
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker-credential-helpers/client"
+	credhelpers "github.com/docker/docker-credential-helpers/credentials"
+)
+
+// mockStore is an in-memory credential helper speaking the helper protocol.
+type mockStore map[string]*credhelpers.Credentials
+
+// mockProgram executes a single action against a mockStore.
+type mockProgram struct {
+	store  mockStore
+	action string
+	input  []byte
+}
+
+func (m *mockProgram) Input(in io.Reader) {
+	m.input, _ = io.ReadAll(in)
+}
+
+func (m *mockProgram) Output() ([]byte, error) {
+	switch m.action {
+	case "get":
+		creds, ok := m.store[string(m.input)]
+		if !ok {
+			return []byte(credhelpers.NewErrCredentialsNotFound().Error()), errors.New("exit status 1")
+		}
+		return json.Marshal(creds)
+	case "store":
+		var creds credhelpers.Credentials
+		if err := json.Unmarshal(m.input, &creds); err != nil {
+			return []byte(err.Error()), errors.New("exit status 1")
+		}
+		m.store[creds.ServerURL] = &creds
+		return nil, nil
+	case "erase":
+		delete(m.store, string(m.input))
+		return nil, nil
+	case "list":
+		accounts := make(map[string]string)
+		for serverURL, creds := range m.store {
+			accounts[serverURL] = creds.Username
+		}
+		return json.Marshal(accounts)
+	default:
+		return []byte("unknown action"), errors.New("exit status 1")
+	}
+}
+
+func (s mockStore) program() client.ProgramFunc {
+	return func(args ...string) client.Program {
+		return &mockProgram{store: s, action: args[0]}
+	}
+}
+
+func TestFallbackHelper(t *testing.T) {
+	store := mockStore{}
+	fallback := &fallbackHelper{Name: "mock", program: store.program()}
+
+	if _, _, found, err := fallback.Get("https://repo.example.com"); found || err != nil {
+		t.Fatalf("Get() actual = (%v, %v), expected (false, <nil>)", found, err)
+	}
+
+	creds := &credhelpers.Credentials{ServerURL: "https://repo.example.com", Username: "u1", Secret: "p1"}
+	if err := fallback.Add(creds); err != nil {
+		t.Fatalf("Add() unexpected error: %v", err)
+	}
+
+	username, password, found, err := fallback.Get("https://repo.example.com")
+	if username != "u1" || password != "p1" || !found || err != nil {
+		t.Errorf("Get() actual = (%v, %v, %v, %v), expected (u1, p1, true, <nil>)", username, password, found, err)
+	}
+
+	accounts, err := fallback.List()
+	if err != nil || len(accounts) != 1 || accounts["https://repo.example.com"] != "u1" {
+		t.Errorf("List() actual = (%v, %v), expected (map[https://repo.example.com:u1], <nil>)", accounts, err)
+	}
+
+	if err := fallback.Delete("https://repo.example.com"); err != nil {
+		t.Fatalf("Delete() unexpected error: %v", err)
+	}
+	if len(store) != 0 {
+		t.Errorf("expected empty store after Delete(), got %v", store)
+	}
+}
+
+func TestNewFallbackHelper(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputEnv map[string]string
+		expected string
+		loop     bool
+	}{
+		{
+			name:     "Not configured",
+			inputEnv: map[string]string{},
+		},
+		{
+			name:     "Configured",
+			inputEnv: map[string]string{"DOCKER_CREDENTIAL_ENV_FALLBACK": "pass"},
+			expected: "pass",
+		},
+		{
+			name:     "Fallback to self",
+			inputEnv: map[string]string{"DOCKER_CREDENTIAL_ENV_FALLBACK": "env"},
+			loop:     true,
+		},
+		{
+			name: "Invoked as a fallback",
+			inputEnv: map[string]string{
+				"DOCKER_CREDENTIAL_ENV_FALLBACK":        "pass",
+				"DOCKER_CREDENTIAL_ENV_FALLBACK_ACTIVE": "true",
+			},
+			loop: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DOCKER_CREDENTIAL_ENV_FALLBACK", "")
+			t.Setenv("DOCKER_CREDENTIAL_ENV_FALLBACK_ACTIVE", "")
+			for k, v := range tt.inputEnv {
+				t.Setenv(k, v)
+			}
+
+			fallback, err := newFallbackHelper()
+			var loopErr *LoopError
+			if errors.As(err, &loopErr) != tt.loop {
+				t.Fatalf("newFallbackHelper() unexpected error: %v", err)
+			}
+			if tt.loop {
+				return
+			}
+			if (fallback == nil && tt.expected != "") || (fallback != nil && fallback.Name != tt.expected) {
+				t.Errorf("newFallbackHelper() actual = %v, expected %q", fallback, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEnvFallback(t *testing.T) {
+	t.Setenv("DOCKER_CREDENTIAL_ENV_FALLBACK", "env")
+	e := Env{}
+
+	if err := e.Add(&credhelpers.Credentials{}); err == nil || !strings.Contains(err.Error(), "fallback loop detected") {
+		t.Errorf("Add() actual = (%v), expected fallback loop error", err)
+	}
+	if err := e.Delete("https://repo.example.com"); err == nil || !strings.Contains(err.Error(), "fallback loop detected") {
+		t.Errorf("Delete() actual = (%v), expected fallback loop error", err)
+	}
+	if _, err := e.List(); err == nil || !strings.Contains(err.Error(), "fallback loop detected") {
+		t.Errorf("List() actual = (%v), expected fallback loop error", err)
+	}
+	if _, _, err := e.Get("https://repo.example.com"); err == nil || !strings.Contains(err.Error(), "fallback loop detected") {
+		t.Errorf("Get() actual = (%v), expected fallback loop error", err)
+	}
+}
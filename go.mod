@@ -11,6 +11,7 @@ require (
 	github.com/docker/cli v29.6.1+incompatible
 	github.com/docker/docker-credential-helpers v0.9.8
 	github.com/goccy/go-yaml v1.19.2
+	golang.org/x/sys v0.42.0
 )
 
 require (
@@ -25,7 +26,6 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.36.6 // indirect
 	github.com/aws/smithy-go v1.27.1 // indirect
 	github.com/sirupsen/logrus v1.9.4 // indirect
-	golang.org/x/sys v0.42.0 // indirect
 	gotest.tools/v3 v3.5.2 // indirect
 )
 
@@ -0,0 +1,223 @@
+// Package main provides in-place editing of JSON objects, preserving formatting and unknown members.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// jsonMember is the position of a member of a JSON object: its key spans data[KeyStart:KeyEnd],
+// and its value data[ValueStart:ValueEnd].
+type jsonMember struct {
+	Key        string
+	KeyStart   int
+	KeyEnd     int
+	ValueStart int
+	ValueEnd   int
+}
+
+// jsonObject is the position of a JSON object: its braces are at data[Open] and data[Close].
+type jsonObject struct {
+	Open    int
+	Close   int
+	Members []jsonMember
+}
+
+// parseJSONObject locates the members of the JSON object starting at data[start:], which may be preceded by
+// whitespace. At the top level (start 0), the object must be the whole document.
+func parseJSONObject(data []byte, start int) (*jsonObject, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data[start:]))
+	offset := func() int { return start + int(decoder.InputOffset()) }
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, errors.New("not a JSON object")
+	}
+	object := &jsonObject{Open: offset() - 1}
+
+	for decoder.More() {
+		keyStart := skipJSONSpace(data, offset(), ',')
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		member := jsonMember{Key: token.(string), KeyStart: keyStart, KeyEnd: offset()}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		member.ValueStart = skipJSONSpace(data, member.KeyEnd, ':')
+		member.ValueEnd = offset()
+		object.Members = append(object.Members, member)
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	object.Close = offset() - 1
+	if start == 0 {
+		if _, err := decoder.Token(); err != io.EOF {
+			return nil, errors.New("unexpected data after JSON object")
+		}
+	}
+	return object, nil
+}
+
+// skipJSONSpace returns the offset of the first byte from offset that is neither whitespace nor the separator.
+func skipJSONSpace(data []byte, offset int, separator byte) int {
+	for offset < len(data) && (strings.IndexByte(" \t\r\n", data[offset]) >= 0 || data[offset] == separator) {
+		offset++
+	}
+	return offset
+}
+
+// lineIndent returns the indentation of the line containing offset.
+func lineIndent(data []byte, offset int) string {
+	start := bytes.LastIndexByte(data[:offset], '\n') + 1
+	end := start
+	for end < len(data) && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return string(data[start:end])
+}
+
+// index returns the index of the member with the key, or -1.
+func (o *jsonObject) index(key string) int {
+	return slices.IndexFunc(o.Members, func(member jsonMember) bool { return member.Key == key })
+}
+
+// member returns the member with the key, or nil.
+func (o *jsonObject) member(key string) *jsonMember {
+	if i := o.index(key); i >= 0 {
+		return &o.Members[i]
+	}
+	return nil
+}
+
+// layout returns the whitespace before each member and the separator between keys and values, following the
+// first member. The members of an empty object are indented by a tab more than its closing brace.
+func (o *jsonObject) layout(data []byte) (leading, colon string) {
+	if len(o.Members) == 0 {
+		return "\n" + lineIndent(data, o.Close) + "\t", ": "
+	}
+	first := o.Members[0]
+	return string(data[o.Open+1 : first.KeyStart]), string(data[first.KeyEnd:first.ValueStart])
+}
+
+// findJSONObject returns the object reached by following the path of member keys from the top-level object,
+// or nil if a member is missing or null.
+func findJSONObject(data []byte, path []string) (*jsonObject, error) {
+	object, err := parseJSONObject(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range path {
+		member := object.member(key)
+		if member == nil || string(data[member.ValueStart:member.ValueEnd]) == "null" {
+			return nil, nil
+		}
+		if object, err = parseJSONObject(data, member.ValueStart); err != nil {
+			return nil, fmt.Errorf("%s: %w", strings.Join(path[:i+1], "."), err)
+		}
+	}
+	return object, nil
+}
+
+// setJSONMember sets a member of the object at the path, replacing its value or appending it, and creating the
+// objects along the path if they are missing or null. The rest of the document is left unchanged, and new values are
+// indented to match the surrounding members.
+func setJSONMember(data []byte, path []string, key string, value any) ([]byte, error) {
+	object, err := findJSONObject(data, path)
+	if err != nil {
+		return nil, err
+	}
+	if object == nil {
+		return setJSONMember(data, path[:len(path)-1], path[len(path)-1], map[string]any{key: value})
+	}
+
+	leading, colon := object.layout(data)
+	var encoded []byte
+	if i := strings.LastIndexByte(leading, '\n'); i >= 0 {
+		indent := leading[i+1:]
+		unit, found := strings.CutPrefix(indent, lineIndent(data, object.Close))
+		if !found || unit == "" {
+			unit = "\t"
+		}
+		encoded, err = json.MarshalIndent(value, indent, unit)
+	} else {
+		encoded, err = json.Marshal(value)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var edited bytes.Buffer
+	if member := object.member(key); member != nil {
+		edited.Write(data[:member.ValueStart])
+		edited.Write(encoded)
+		edited.Write(data[member.ValueEnd:])
+		return edited.Bytes(), nil
+	}
+
+	encodedKey, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(object.Members) == 0 {
+		edited.Write(data[:object.Open+1])
+		edited.WriteString(leading)
+		edited.Write(encodedKey)
+		edited.WriteString(colon)
+		edited.Write(encoded)
+		edited.WriteString("\n" + lineIndent(data, object.Close))
+		edited.Write(data[object.Close:])
+		return edited.Bytes(), nil
+	}
+	last := object.Members[len(object.Members)-1]
+	edited.Write(data[:last.ValueEnd])
+	edited.WriteString("," + leading)
+	edited.Write(encodedKey)
+	edited.WriteString(colon)
+	edited.Write(encoded)
+	edited.Write(data[last.ValueEnd:])
+	return edited.Bytes(), nil
+}
+
+// deleteJSONMember removes a member of the object at the path, if present, leaving the rest of the document unchanged.
+// If removeEmpty is set and the object becomes empty, it is removed from its parent too.
+func deleteJSONMember(data []byte, path []string, key string, removeEmpty bool) ([]byte, error) {
+	object, err := findJSONObject(data, path)
+	if err != nil || object == nil {
+		return data, err
+	}
+	i := object.index(key)
+	if i < 0 {
+		return data, nil
+	}
+	member := object.Members[i]
+	if removeEmpty && len(path) > 0 && len(object.Members) == 1 {
+		return deleteJSONMember(data, path[:len(path)-1], path[len(path)-1], removeEmpty)
+	}
+
+	var edited bytes.Buffer
+	switch {
+	case len(object.Members) == 1:
+		edited.Write(data[:object.Open+1])
+		edited.Write(data[object.Close:])
+	case i == 0:
+		edited.Write(data[:member.KeyStart])
+		edited.Write(data[object.Members[1].KeyStart:])
+	default:
+		edited.Write(data[:object.Members[i-1].ValueEnd])
+		edited.Write(data[member.ValueEnd:])
+	}
+	return edited.Bytes(), nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSetJSONMember(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		path     []string
+		key      string
+		value    any
+		expected string
+	}{
+		{
+			name:     "Empty object",
+			input:    "{}",
+			key:      "credsStore",
+			value:    "env",
+			expected: "{\n\t\"credsStore\": \"env\"\n}",
+		},
+		{
+			name:     "Replace value",
+			input:    "{\n  \"credsStore\":   \"desktop\",\n  \"x-tool\": {\"a\": [1, 2]}\n}\n",
+			key:      "credsStore",
+			value:    "env",
+			expected: "{\n  \"credsStore\":   \"env\",\n  \"x-tool\": {\"a\": [1, 2]}\n}\n",
+		},
+		{
+			name:     "Append member",
+			input:    "{\n  \"auths\": {},\n  \"x-tool\": true\n}\n",
+			key:      "credsStore",
+			value:    "env",
+			expected: "{\n  \"auths\": {},\n  \"x-tool\": true,\n  \"credsStore\": \"env\"\n}\n",
+		},
+		{
+			name:     "Compact object",
+			input:    `{"auths":{},"x-tool":true}`,
+			key:      "credsStore",
+			value:    "env",
+			expected: `{"auths":{},"x-tool":true,"credsStore":"env"}`,
+		},
+		{
+			name:     "Append to nested object",
+			input:    "{\n\t\"credHelpers\": {\n\t\t\"gcr.io\": \"gcloud\"\n\t},\n\t\"x-tool\": true\n}",
+			path:     []string{"credHelpers"},
+			key:      "ghcr.io",
+			value:    "env",
+			expected: "{\n\t\"credHelpers\": {\n\t\t\"gcr.io\": \"gcloud\",\n\t\t\"ghcr.io\": \"env\"\n\t},\n\t\"x-tool\": true\n}",
+		},
+		{
+			name:     "Empty nested object",
+			input:    "{\n\t\"credHelpers\": {},\n\t\"x-tool\": true\n}",
+			path:     []string{"credHelpers"},
+			key:      "ghcr.io",
+			value:    "env",
+			expected: "{\n\t\"credHelpers\": {\n\t\t\"ghcr.io\": \"env\"\n\t},\n\t\"x-tool\": true\n}",
+		},
+		{
+			name:     "Missing nested object",
+			input:    "{\n    \"x-tool\": true\n}",
+			path:     []string{"credHelpers"},
+			key:      "ghcr.io",
+			value:    "env",
+			expected: "{\n    \"x-tool\": true,\n    \"credHelpers\": {\n        \"ghcr.io\": \"env\"\n    }\n}",
+		},
+		{
+			name:     "Null nested object",
+			input:    "{\n\t\"credHelpers\": null,\n\t\"x-tool\": true\n}",
+			path:     []string{"credHelpers"},
+			key:      "ghcr.io",
+			value:    "env",
+			expected: "{\n\t\"credHelpers\": {\n\t\t\"ghcr.io\": \"env\"\n\t},\n\t\"x-tool\": true\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := setJSONMember([]byte(tt.input), tt.path, tt.key, tt.value)
+			if err != nil {
+				t.Fatalf("setJSONMember() unexpected error: %v", err)
+			}
+			if string(actual) != tt.expected {
+				t.Errorf("setJSONMember(%q) actual = (%q), expected (%q)", tt.input, actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDeleteJSONMember(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		path        []string
+		key         string
+		removeEmpty bool
+		expected    string
+	}{
+		{
+			name:     "First member",
+			input:    "{\n\t\"credsStore\": \"env\",\n\t\"x-tool\": true\n}\n",
+			key:      "credsStore",
+			expected: "{\n\t\"x-tool\": true\n}\n",
+		},
+		{
+			name:     "Last member",
+			input:    "{\n\t\"x-tool\": true,\n\t\"credsStore\": \"env\"\n}\n",
+			key:      "credsStore",
+			expected: "{\n\t\"x-tool\": true\n}\n",
+		},
+		{
+			name:     "Only member",
+			input:    "{\n\t\"credsStore\": \"env\"\n}",
+			key:      "credsStore",
+			expected: "{}",
+		},
+		{
+			name:     "Missing member",
+			input:    "{\n\t\"x-tool\": true\n}",
+			key:      "credsStore",
+			expected: "{\n\t\"x-tool\": true\n}",
+		},
+		{
+			name:     "Nested member",
+			input:    "{\n\t\"credHelpers\": {\n\t\t\"gcr.io\": \"gcloud\",\n\t\t\"ghcr.io\": \"env\"\n\t}\n}",
+			path:     []string{"credHelpers"},
+			key:      "ghcr.io",
+			expected: "{\n\t\"credHelpers\": {\n\t\t\"gcr.io\": \"gcloud\"\n\t}\n}",
+		},
+		{
+			name:        "Empty nested object is removed",
+			input:       "{\n\t\"credHelpers\": {\n\t\t\"ghcr.io\": \"env\"\n\t},\n\t\"x-tool\": true\n}",
+			path:        []string{"credHelpers"},
+			key:         "ghcr.io",
+			removeEmpty: true,
+			expected:    "{\n\t\"x-tool\": true\n}",
+		},
+		{
+			name:     "Missing nested object",
+			input:    "{\n\t\"x-tool\": true\n}",
+			path:     []string{"credHelpers"},
+			key:      "ghcr.io",
+			expected: "{\n\t\"x-tool\": true\n}",
+		},
+		{
+			name:     "Null nested object",
+			input:    "{\n\t\"credHelpers\": null\n}",
+			path:     []string{"credHelpers"},
+			key:      "ghcr.io",
+			expected: "{\n\t\"credHelpers\": null\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := deleteJSONMember([]byte(tt.input), tt.path, tt.key, tt.removeEmpty)
+			if err != nil {
+				t.Fatalf("deleteJSONMember() unexpected error: %v", err)
+			}
+			if string(actual) != tt.expected {
+				t.Errorf("deleteJSONMember(%q) actual = (%q), expected (%q)", tt.input, actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseJSONObject_Errors(t *testing.T) {
+	for _, input := range []string{"", "[]", `{"a": }`, `{"a": 1} {}`, `{"credHelpers": []}`} {
+		t.Run(input, func(t *testing.T) {
+			if _, err := setJSONMember([]byte(input), []string{"credHelpers"}, "ghcr.io", "env"); err == nil {
+				t.Errorf("setJSONMember(%q) expected an error but got none", input)
+			}
+		})
+	}
+}
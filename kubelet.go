@@ -0,0 +1,116 @@
+// Package main provides the Kubernetes kubelet image credential provider command.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// kubeletRequestKind and kubeletResponseKind are the kinds of the kubelet CredentialProvider exec plugin API.
+	kubeletRequestKind  = "CredentialProviderRequest"
+	kubeletResponseKind = "CredentialProviderResponse"
+	// kubeletCacheKeyType caches the returned credentials per registry, as they do not depend on the image.
+	kubeletCacheKeyType = "Registry"
+)
+
+// kubeletAPIVersions are the supported versions of the kubelet CredentialProvider exec plugin API,
+// which share the same request and response formats.
+var kubeletAPIVersions = []string{
+	"credentialprovider.kubelet.k8s.io/v1",
+	"credentialprovider.kubelet.k8s.io/v1beta1",
+}
+
+// kubeletRequest is the CredentialProviderRequest read from standard input.
+type kubeletRequest struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Image      string `json:"image"`
+}
+
+// kubeletAuthConfig is the credentials returned for a registry.
+type kubeletAuthConfig struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// kubeletResponse is the CredentialProviderResponse written to standard output.
+// Without a cache duration, the kubelet applies the defaultCacheDuration of its provider configuration.
+type kubeletResponse struct {
+	APIVersion    string                       `json:"apiVersion"`
+	Kind          string                       `json:"kind"`
+	CacheKeyType  string                       `json:"cacheKeyType"`
+	CacheDuration string                       `json:"cacheDuration,omitempty"`
+	Auth          map[string]kubeletAuthConfig `json:"auth"`
+}
+
+// getImageRegistry returns the registry of an image reference, following the Docker conventions:
+// the first path component is a registry if it contains a dot or a port, or is localhost.
+// Other references, such as "nginx" or "library/nginx", are on Docker Hub.
+func getImageRegistry(image string) string {
+	registry, _, found := strings.Cut(image, "/")
+	if !found || (!strings.ContainsAny(registry, ".:") && registry != "localhost") {
+		return dockerHubHostname
+	}
+	return registry
+}
+
+// RunKubeletProviderCommand serves a single kubelet CredentialProvider exec plugin request, resolving the
+// credentials for the image registry in the same way as the get verb. The cache duration is set from the
+// expiry of the credentials, such as ECR tokens, when the provider reports one.
+func RunKubeletProviderCommand(args []string, in io.Reader, out io.Writer) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments\nUsage: docker-credential-env kubelet-provider < request.json")
+	}
+
+	var request kubeletRequest
+	if err := json.NewDecoder(in).Decode(&request); err != nil {
+		return fmt.Errorf("invalid %s: %w", kubeletRequestKind, err)
+	}
+	if !slices.Contains(kubeletAPIVersions, request.APIVersion) {
+		return fmt.Errorf("unsupported apiVersion %q, expected one of: %s", request.APIVersion, strings.Join(kubeletAPIVersions, ", "))
+	}
+	if request.Kind != kubeletRequestKind {
+		return fmt.Errorf("unsupported kind %q, expected %s", request.Kind, kubeletRequestKind)
+	}
+	if request.Image == "" {
+		return fmt.Errorf("invalid %s: image must not be empty", kubeletRequestKind)
+	}
+
+	registry := getImageRegistry(request.Image)
+	ctx, expiry := withExpiry(context.Background())
+	username, password, found, err := resolveServerCredentials(ctx, registry)
+	if err != nil {
+		return err
+	}
+
+	response := kubeletResponse{
+		APIVersion:   request.APIVersion,
+		Kind:         kubeletResponseKind,
+		CacheKeyType: kubeletCacheKeyType,
+		Auth:         map[string]kubeletAuthConfig{},
+	}
+	if found {
+		if username == identityTokenUsername {
+			return fmt.Errorf("identity token credentials for %q are not supported by the kubelet", registry)
+		}
+		response.Auth[registry] = kubeletAuthConfig{Username: username, Password: password}
+		if !expiry.IsZero() {
+			response.CacheDuration = kubeletCacheDuration(*expiry)
+		}
+	}
+
+	return json.NewEncoder(out).Encode(response)
+}
+
+// kubeletCacheDuration returns how long the kubelet may cache credentials expiring at expiry, or "0s", which disables
+// caching, if they expire within expiryMargin.
+func kubeletCacheDuration(expiry time.Time) string {
+	return max(time.Until(expiry)-expiryMargin, 0).Truncate(time.Second).String()
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetImageRegistry(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "nginx", expected: "docker.io"},
+		{input: "nginx:1.27", expected: "docker.io"},
+		{input: "library/nginx@sha256:0123", expected: "docker.io"},
+		{input: "docker.io/library/nginx", expected: "docker.io"},
+		{input: "123456789012.dkr.ecr.eu-west-1.amazonaws.com/app:v1", expected: "123456789012.dkr.ecr.eu-west-1.amazonaws.com"},
+		{input: "registry.example.com:5000/team/app", expected: "registry.example.com:5000"},
+		{input: "localhost/app", expected: "localhost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if actual := getImageRegistry(tt.input); actual != tt.expected {
+				t.Errorf("getImageRegistry(%v) actual = (%v), expected (%v)", tt.input, actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRunKubeletProviderCommand(t *testing.T) {
+	t.Setenv("DOCKER_registry_example_com_USR", "u1")
+	t.Setenv("DOCKER_registry_example_com_PSW", "p1")
+	t.Setenv("DOCKER_token_example_com_TOKEN", "t1")
+
+	tests := []struct {
+		name        string
+		args        []string
+		input       string
+		expected    kubeletResponse
+		errContains string
+	}{
+		{
+			name:  "Credentials found",
+			input: `{"apiVersion":"credentialprovider.kubelet.k8s.io/v1","kind":"CredentialProviderRequest","image":"registry.example.com/team/app:v1"}`,
+			expected: kubeletResponse{
+				APIVersion:   "credentialprovider.kubelet.k8s.io/v1",
+				Kind:         "CredentialProviderResponse",
+				CacheKeyType: "Registry",
+				Auth:         map[string]kubeletAuthConfig{"registry.example.com": {Username: "u1", Password: "p1"}},
+			},
+		},
+		{
+			name:  "No credentials",
+			input: `{"apiVersion":"credentialprovider.kubelet.k8s.io/v1beta1","kind":"CredentialProviderRequest","image":"other.example.com/app"}`,
+			expected: kubeletResponse{
+				APIVersion:   "credentialprovider.kubelet.k8s.io/v1beta1",
+				Kind:         "CredentialProviderResponse",
+				CacheKeyType: "Registry",
+				Auth:         map[string]kubeletAuthConfig{},
+			},
+		},
+		{
+			name:        "Identity token",
+			input:       `{"apiVersion":"credentialprovider.kubelet.k8s.io/v1","kind":"CredentialProviderRequest","image":"token.example.com/app"}`,
+			errContains: "identity token",
+		},
+		{
+			name:        "Unsupported apiVersion",
+			input:       `{"apiVersion":"credentialprovider.kubelet.k8s.io/v2","kind":"CredentialProviderRequest","image":"registry.example.com/app"}`,
+			errContains: `unsupported apiVersion "credentialprovider.kubelet.k8s.io/v2"`,
+		},
+		{
+			name:        "Unsupported kind",
+			input:       `{"apiVersion":"credentialprovider.kubelet.k8s.io/v1","kind":"Pod","image":"registry.example.com/app"}`,
+			errContains: `unsupported kind "Pod"`,
+		},
+		{
+			name:        "Missing image",
+			input:       `{"apiVersion":"credentialprovider.kubelet.k8s.io/v1","kind":"CredentialProviderRequest"}`,
+			errContains: "image must not be empty",
+		},
+		{
+			name:        "Invalid JSON",
+			input:       `{`,
+			errContains: "invalid CredentialProviderRequest",
+		},
+		{
+			name:        "Too many arguments",
+			args:        []string{"extra"},
+			errContains: "too many arguments",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := RunKubeletProviderCommand(tt.args, strings.NewReader(tt.input), &out)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("Expected error to contain %q, but got %v", tt.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RunKubeletProviderCommand() unexpected error: %v", err)
+			}
+
+			var actual kubeletResponse
+			if err := json.Unmarshal(out.Bytes(), &actual); err != nil {
+				t.Fatalf("RunKubeletProviderCommand() invalid response %q: %v", out.String(), err)
+			}
+			actualJSON, _ := json.Marshal(actual)
+			expectedJSON, _ := json.Marshal(tt.expected)
+			if !bytes.Equal(actualJSON, expectedJSON) {
+				t.Errorf("RunKubeletProviderCommand() actual = (%s), expected (%s)", actualJSON, expectedJSON)
+			}
+		})
+	}
+}
+
+func TestRunKubeletProviderCommand_CacheDuration(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake plugin requires a POSIX shell")
+	}
+
+	binDir := t.TempDir()
+	pluginPath := filepath.Join(binDir, "docker-credential-env-broker")
+	writeFile(t, pluginPath, fakePluginScript)
+	if err := os.Chmod(pluginPath, 0700); err != nil { // #nosec G302
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("PLUGIN_CALLS", filepath.Join(t.TempDir(), "calls"))
+	t.Setenv("PLUGIN_EXPIRY", time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("DOCKER_CREDENTIAL_ENV_PLUGINS", "broker=*.example.com")
+
+	input := `{"apiVersion":"credentialprovider.kubelet.k8s.io/v1","kind":"CredentialProviderRequest","image":"broker.example.com/app"}`
+	var out bytes.Buffer
+	if err := RunKubeletProviderCommand(nil, strings.NewReader(input), &out); err != nil {
+		t.Fatalf("RunKubeletProviderCommand() unexpected error: %v", err)
+	}
+
+	var actual kubeletResponse
+	if err := json.Unmarshal(out.Bytes(), &actual); err != nil {
+		t.Fatalf("RunKubeletProviderCommand() invalid response %q: %v", out.String(), err)
+	}
+	if auth := actual.Auth["broker.example.com"]; auth.Username != "u1" || auth.Password != "s1" {
+		t.Errorf("RunKubeletProviderCommand() auth actual = (%v, %v), expected (u1, s1)", auth.Username, auth.Password)
+	}
+	cacheDuration, err := time.ParseDuration(actual.CacheDuration)
+	if err != nil || cacheDuration < time.Hour-expiryMargin-time.Minute || cacheDuration > time.Hour-expiryMargin {
+		t.Errorf("RunKubeletProviderCommand() cacheDuration actual = (%v), expected just under %v", actual.CacheDuration, time.Hour-expiryMargin)
+	}
+}
+
+func TestKubeletCacheDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    time.Duration
+		minimum  time.Duration
+		maximum  time.Duration
+		expected string
+	}{
+		{
+			name:    "Long-lived",
+			input:   time.Hour,
+			minimum: time.Hour - expiryMargin - 2*time.Second,
+			maximum: time.Hour - expiryMargin,
+		},
+		{
+			name:    "Just beyond the margin",
+			input:   expiryMargin + 10*time.Second,
+			minimum: 8 * time.Second,
+			maximum: 10 * time.Second,
+		},
+		{
+			name:     "Within the margin",
+			input:    expiryMargin - time.Second,
+			expected: "0s",
+		},
+		{
+			name:     "Expired",
+			input:    -time.Minute,
+			expected: "0s",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := kubeletCacheDuration(time.Now().Add(tt.input))
+			if tt.expected != "" {
+				if actual != tt.expected {
+					t.Errorf("kubeletCacheDuration(%v) actual = (%v), expected (%v)", tt.input, actual, tt.expected)
+				}
+				return
+			}
+			duration, err := time.ParseDuration(actual)
+			if err != nil || duration < tt.minimum || duration > tt.maximum {
+				t.Errorf("kubeletCacheDuration(%v) actual = (%v), expected between %v and %v", tt.input, actual, tt.minimum, tt.maximum)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !(darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || windows)
+
+// Package main provides no-op file locking on platforms without advisory locks.
+package main
+
+import "os"
+
+// lockFileExclusive does nothing, as advisory locks are not supported on this platform.
+func lockFileExclusive(*os.File) error {
+	return nil
+}
+
+// unlockFile does nothing, as advisory locks are not supported on this platform.
+func unlockFile(*os.File) error {
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd
+
+// Package main provides advisory file locking using flock(2).
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFileExclusive blocks until it holds an exclusive advisory lock on the file.
+func lockFileExclusive(f *os.File) error {
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX) // #nosec G115 -- file descriptors fit in an int
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+// unlockFile releases the lock taken by lockFileExclusive.
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN) // #nosec G115 -- file descriptors fit in an int
+}
@@ -2,7 +2,7 @@
 // the process environment.
 //
 // It supports both general environment variables (DOCKER_*_USR/PSW) and specialised
-// credentials for AWS ECR and GitHub Container Registry.
+// credentials for AWS ECR, Azure Container Registry and GitHub Container Registry.
 //
 // For more details, see the project README.md.
 
@@ -28,6 +28,55 @@ func main() {
 		return
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		if err := RunValidateCommand(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Validation failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "kubelet-provider" {
+		if err := RunKubeletProviderCommand(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Kubelet credential provider failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "exec" {
+		code, err := RunExecCommand(os.Args[2:], os.Stdin, os.Stdout, os.Stderr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Exec failed: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(code)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "resolve" {
+		if err := RunResolveCommand(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Resolve failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		if err := RunDoctorCommand(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Doctor failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "session" {
+		if err := RunSessionCommand(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Session failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// If not a setup command, serve as a credential helper
 	credhelpers.Serve(&Env{})
 }
@@ -0,0 +1,219 @@
+// Package main provides external exec-plugin credential provider implementations.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// pluginPrefix is the executable name prefix of exec-plugin providers: docker-credential-env-<name>.
+	pluginPrefix = "docker-credential-env-"
+	// pluginProtocolVersion is the version of the JSON request sent to plugins.
+	pluginProtocolVersion = 1
+	// defaultPluginTimeout bounds the time a plugin may run, unless overridden by DOCKER_CREDENTIAL_ENV_PLUGIN_TIMEOUT.
+	defaultPluginTimeout = 10 * time.Second
+	// commandWaitDelay bounds the time waited for the output of a command (a plugin, an exec: reference or a Git
+	// credential helper) that has been killed on timeout, which may be held open by its child processes.
+	commandWaitDelay = 500 * time.Millisecond
+	// maxPluginResponseSize bounds the size of a plugin response.
+	maxPluginResponseSize = 1 << 20
+)
+
+// pluginRequest is written to the standard input of a plugin.
+type pluginRequest struct {
+	Version   int    `json:"version"`
+	Hostname  string `json:"hostname"`
+	ServerURL string `json:"serverURL"`
+}
+
+// pluginResponse is read from the standard output of a plugin. An empty secret means that the plugin
+// has no credentials for the hostname. A non-zero expiry allows the response to be cached until then.
+type pluginResponse struct {
+	Username  string    `json:"username"`
+	Secret    string    `json:"secret"`
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
+}
+
+// cacheExpiry implements cacheable. Responses without a secret are never cached.
+func (r pluginResponse) cacheExpiry() time.Time {
+	if r.Secret == "" {
+		return time.Time{}
+	}
+	return r.ExpiresAt
+}
+
+// plugin is an external exec-plugin provider, run either from Path or as docker-credential-env-<Name> found on PATH.
+type plugin struct {
+	Name    string
+	Path    string
+	Timeout time.Duration
+}
+
+// getPluginTimeout returns the timeout configured by DOCKER_CREDENTIAL_ENV_PLUGIN_TIMEOUT, or the default.
+func getPluginTimeout() (time.Duration, error) {
+	value := os.Getenv(envPluginTimeout)
+	if value == "" {
+		return defaultPluginTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", envPluginTimeout, value)
+	}
+	return timeout, nil
+}
+
+// executable returns the path of the plugin executable.
+func (p *plugin) executable() (string, error) {
+	if p.Path != "" {
+		return p.Path, nil
+	}
+	executable, err := exec.LookPath(pluginPrefix + p.Name)
+	if err != nil {
+		return "", fmt.Errorf("plugin %q: %w", p.Name, err)
+	}
+	return executable, nil
+}
+
+// Get runs the plugin for the hostname, reusing a cached response until it expires.
+// Returns the username, password, a boolean indicating if credentials were found, and any execution error.
+func (p *plugin) Get(ctx context.Context, hostname, serverURL string) (username, password string, found bool, err error) {
+	executable, err := p.executable()
+	if err != nil {
+		return "", "", false, err
+	}
+
+	cacheFile := cachePath("plugins", executable, hostname)
+	if response, ok := readCache[pluginResponse](cacheFile); ok {
+		reportExpiry(ctx, response.ExpiresAt)
+		return response.Username, response.Secret, true, nil
+	}
+
+	response, err := p.run(ctx, executable, pluginRequest{Version: pluginProtocolVersion, Hostname: hostname, ServerURL: serverURL})
+	if err != nil {
+		return "", "", false, err
+	}
+	if response.Secret == "" {
+		return "", "", false, nil
+	}
+
+	reportExpiry(ctx, response.ExpiresAt)
+	if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+		if !response.ExpiresAt.IsZero() {
+			expiration := response.ExpiresAt.UTC().Format(time.RFC3339)
+			_, _ = fmt.Fprintf(os.Stderr, "Plugin %q credentials for %q will expire at %s (UTC)\n", executable, hostname, expiration)
+		}
+	}
+
+	writeCache(cacheFile, *response)
+
+	return response.Username, response.Secret, true, nil
+}
+
+// run executes the plugin with the request on standard input, and decodes the response from standard output.
+// The plugin's standard error is passed through in debug mode, and discarded otherwise.
+func (p *plugin) run(ctx context.Context, executable string, request pluginRequest) (*pluginResponse, error) {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		var err error
+		if timeout, err = getPluginTimeout(); err != nil {
+			return nil, err
+		}
+	}
+
+	input, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %q: %w", executable, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, executable) // #nosec G204 -- plugin is supplied by the operator
+	cmd.WaitDelay = commandWaitDelay
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &limitedWriter{w: &stdout, n: maxPluginResponseSize}
+	cmd.Stderr = io.Discard
+	if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+		// Not os.Stderr itself, which background processes of the plugin could otherwise hold open after a timeout
+		cmd.Stderr = struct{ io.Writer }{os.Stderr}
+	}
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("plugin %q timed out after %s", executable, timeout)
+		}
+		return nil, fmt.Errorf("plugin %q failed: %w", executable, err)
+	}
+
+	var response pluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return nil, fmt.Errorf("plugin %q: invalid response: %w", executable, err)
+	}
+	return &response, nil
+}
+
+// pluginEntry is a plugin listed in DOCKER_CREDENTIAL_ENV_PLUGINS, with the hostname glob it applies to.
+type pluginEntry struct {
+	Plugin plugin
+	Host   string
+}
+
+// getPluginEntries parses DOCKER_CREDENTIAL_ENV_PLUGINS, a comma-separated list of <name>=<host glob> entries.
+// The glob is required, as the plugin runs for each matching lookup; "*" consults it for every hostname.
+func getPluginEntries() (entries []pluginEntry, err error) {
+	for _, entry := range strings.Split(os.Getenv(envPlugins), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, host, found := strings.Cut(entry, "=")
+		if !found || host == "" {
+			return nil, fmt.Errorf("invalid %s entry %q: missing host glob, e.g. %s=*.example.com", envPlugins, entry, name)
+		}
+		if name == "" || strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("invalid %s entry %q: invalid plugin name", envPlugins, entry)
+		}
+		if _, err := path.Match(host, ""); err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %w", envPlugins, entry, err)
+		}
+		entries = append(entries, pluginEntry{Plugin: plugin{Name: name}, Host: host})
+	}
+	return entries, nil
+}
+
+// pluginsProvider consults the plugins listed in DOCKER_CREDENTIAL_ENV_PLUGINS whose glob matches the hostname, in order.
+type pluginsProvider struct{}
+
+// Match implements Provider.
+func (*pluginsProvider) Match(string) bool { return os.Getenv(envPlugins) != "" }
+
+// Get implements Provider.
+func (*pluginsProvider) Get(ctx context.Context, host string) (username, password string, found bool, err error) {
+	entries, err := getPluginEntries()
+	if err != nil {
+		return "", "", false, err
+	}
+	for _, entry := range entries {
+		if matched, err := path.Match(entry.Host, host); err != nil || !matched {
+			continue
+		}
+		if username, password, found, err = entry.Plugin.Get(ctx, host, serverURLFromContext(ctx, host)); found || err != nil {
+			return
+		}
+	}
+	return "", "", false, nil
+}
+
+// Describe implements Provider.
+func (*pluginsProvider) Describe() string { return "exec plugins" }
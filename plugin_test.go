@@ -0,0 +1,178 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakePluginScript stands in for a token broker, answering for broker.example.com only.
+// Each invocation is appended to $PLUGIN_CALLS, and $PLUGIN_EXPIRY is returned as the expiry.
+const fakePluginScript = `#!/bin/sh
+request=$(cat)
+echo "$request" >> "$PLUGIN_CALLS"
+case "$request" in
+*'"hostname":"broker.example.com"'*)
+	printf '{"username":"u1","secret":"s1","expiresAt":"%s"}\n' "$PLUGIN_EXPIRY" ;;
+*'"hostname":"slow.example.com"'*)
+	sleep 5 ;;
+*'"hostname":"broken.example.com"'*)
+	echo "broker unavailable" >&2
+	exit 1 ;;
+*)
+	echo '{}' ;;
+esac
+`
+
+func TestPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake plugin requires a POSIX shell")
+	}
+
+	binDir := t.TempDir()
+	writeFile(t, filepath.Join(binDir, "docker-credential-env-broker"), fakePluginScript)
+	if err := os.Chmod(filepath.Join(binDir, "docker-credential-env-broker"), 0700); err != nil { // #nosec G302
+		t.Fatal(err)
+	}
+	callsFile := filepath.Join(t.TempDir(), "calls")
+
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("PLUGIN_CALLS", callsFile)
+	t.Setenv("DOCKER_CREDENTIAL_ENV_PLUGIN_TIMEOUT", "500ms")
+
+	type output struct {
+		username    string
+		password    string
+		found       bool
+		errContains string
+		calls       int
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expiry   string
+		expected output
+	}{
+		{
+			name:     "Credentials without expiry are not cached",
+			input:    "broker.example.com",
+			expiry:   "0001-01-01T00:00:00Z",
+			expected: output{username: "u1", password: "s1", found: true, calls: 2},
+		},
+		{
+			name:     "Credentials are cached until expiry",
+			input:    "broker.example.com",
+			expiry:   "2999-01-01T00:00:00Z",
+			expected: output{username: "u1", password: "s1", found: true, calls: 1},
+		},
+		{
+			name:     "Expired credentials are not cached",
+			input:    "broker.example.com",
+			expiry:   "2001-01-01T00:00:00Z",
+			expected: output{username: "u1", password: "s1", found: true, calls: 2},
+		},
+		{
+			name:     "No credentials",
+			input:    "other.example.com",
+			expected: output{found: false, calls: 2},
+		},
+		{
+			name:     "Failure",
+			input:    "broken.example.com",
+			expected: output{errContains: "failed", calls: 2},
+		},
+		{
+			name:     "Timeout",
+			input:    "slow.example.com",
+			expected: output{errContains: "timed out after 500ms", calls: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+			t.Setenv("PLUGIN_EXPIRY", tt.expiry)
+			_ = os.Remove(callsFile)
+
+			p := plugin{Name: "broker"}
+			for range 2 {
+				actualUsername, actualPassword, actualFound, err := p.Get(t.Context(), tt.input, "https://"+tt.input)
+				if tt.expected.errContains != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.expected.errContains) {
+						t.Fatalf("Expected error to contain %q, but got %v", tt.expected.errContains, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("Get(%v) unexpected error: %v", tt.input, err)
+				}
+				if actualUsername != tt.expected.username || actualPassword != tt.expected.password || actualFound != tt.expected.found {
+					t.Errorf("Get(%v) actual = (%v, %v, %v), expected (%v, %v, %v)", tt.input, actualUsername, actualPassword, actualFound, tt.expected.username, tt.expected.password, tt.expected.found)
+				}
+			}
+
+			calls, _ := os.ReadFile(callsFile)
+			if actual := strings.Count(string(calls), `"version":1`); actual != tt.expected.calls {
+				t.Errorf("Get(%v) calls actual = (%v), expected (%v)", tt.input, actual, tt.expected.calls)
+			}
+		})
+	}
+
+	t.Run("Missing plugin", func(t *testing.T) {
+		p := plugin{Name: "missing"}
+		if _, _, _, err := p.Get(t.Context(), "broker.example.com", "broker.example.com"); err == nil {
+			t.Error("expected an error but got none")
+		}
+	})
+}
+
+func TestEnvGet_Plugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake plugin requires a POSIX shell")
+	}
+
+	binDir := t.TempDir()
+	pluginPath := filepath.Join(binDir, "docker-credential-env-broker")
+	writeFile(t, pluginPath, fakePluginScript)
+	if err := os.Chmod(pluginPath, 0700); err != nil { // #nosec G302
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("PLUGIN_CALLS", filepath.Join(t.TempDir(), "calls"))
+	t.Setenv("PLUGIN_EXPIRY", "0001-01-01T00:00:00Z")
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("DOCKER_example_com_USR", "u0")
+	t.Setenv("DOCKER_example_com_PSW", "p0")
+
+	e := Env{}
+
+	t.Setenv("DOCKER_CREDENTIAL_ENV_PLUGINS", "broker=*.example.com")
+	if username, password, err := e.Get("https://broker.example.com"); err != nil || username != "u1" || password != "s1" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (u1, s1, <nil>)", username, password, err)
+	}
+	if username, password, err := e.Get("https://other.example.com"); err != nil || username != "u0" || password != "p0" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (u0, p0, <nil>)", username, password, err)
+	}
+
+	t.Setenv("DOCKER_CREDENTIAL_ENV_PLUGINS", "broker=*.example.net")
+	if username, password, err := e.Get("https://broker.example.com"); err != nil || username != "u0" || password != "p0" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (u0, p0, <nil>)", username, password, err)
+	}
+
+	t.Setenv("DOCKER_CREDENTIAL_ENV_PLUGINS", "broker")
+	if _, _, err := e.Get("https://broker.example.com"); err == nil || !strings.Contains(err.Error(), "missing host glob") {
+		t.Errorf("Expected error to contain %q, but got %v", "missing host glob", err)
+	}
+
+	t.Setenv("DOCKER_CREDENTIAL_ENV_PLUGINS", "")
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, configFile, "rules:\n  - host: broker.example.com\n    provider: plugin\n    params:\n      path: "+pluginPath+"\n      timeout: 5s\n")
+	t.Setenv("DOCKER_CREDENTIAL_ENV_CONFIG", configFile)
+	if username, password, err := e.Get("https://broker.example.com"); err != nil || username != "u1" || password != "s1" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (u1, s1, <nil>)", username, password, err)
+	}
+}
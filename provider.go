@@ -0,0 +1,140 @@
+// Package main provides the pluggable credential provider registry.
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Provider is a source of registry credentials.
+type Provider interface {
+	// Match reports whether the provider applies to the hostname in the current environment.
+	Match(host string) bool
+	// Get retrieves credentials for the hostname.
+	// Returns the username, password, a boolean indicating if credentials were found, and any error.
+	Get(ctx context.Context, host string) (username, password string, found bool, err error)
+	// Describe returns a short human-readable description of the provider.
+	Describe() string
+}
+
+// providerRegistry is the registry of built-in providers, by name.
+var providerRegistry = map[string]Provider{
+	"config":       &configProvider{},
+	"plugins":      &pluginsProvider{},
+	"dockerhub":    &dockerHubProvider{},
+	"env":          &envProvider{},
+	"session":      &sessionProvider{},
+	"dockerconfig": &dockerConfigProvider{},
+	"vault":        &vaultProvider{},
+	"gitlab":       &gitlabProvider{},
+	"ecr":          &ecrProvider{},
+	"acr":          &acrProvider{},
+	"github":       &ghcrProvider{},
+	"netrc":        &netrcProvider{},
+	"git":          &gitProvider{},
+	"fallback":     &fallbackProvider{},
+}
+
+// defaultProviderOrder is the resolution order used unless DOCKER_CREDENTIAL_ENV_PROVIDERS is set.
+var defaultProviderOrder = []string{
+	"config",
+	"plugins",
+	"dockerhub",
+	"env",
+	"session",
+	"dockerconfig",
+	"vault",
+	"gitlab",
+	"ecr",
+	"acr",
+	"github",
+	"netrc",
+	"git",
+	"fallback",
+}
+
+// serverURLKey is the context key for the server URL of the request being resolved.
+type serverURLKey struct{}
+
+// withServerURL returns a context carrying the server URL, for providers that need more than the hostname.
+func withServerURL(ctx context.Context, serverURL string) context.Context {
+	return context.WithValue(ctx, serverURLKey{}, serverURL)
+}
+
+// serverURLFromContext returns the server URL carried by the context, or host if there is none.
+func serverURLFromContext(ctx context.Context, host string) string {
+	if serverURL, ok := ctx.Value(serverURLKey{}).(string); ok && serverURL != "" {
+		return serverURL
+	}
+	return host
+}
+
+// expiryKey is the context key for the expiry reported by the provider of the credentials being resolved.
+type expiryKey struct{}
+
+// withExpiry returns a context in which providers can report when the credentials they return expire,
+// and the time they reported, which remains zero if none did.
+func withExpiry(ctx context.Context) (context.Context, *time.Time) {
+	expiry := new(time.Time)
+	return context.WithValue(ctx, expiryKey{}, expiry), expiry
+}
+
+// reportExpiry records the expiry of the credentials being returned, if the context asks for it.
+func reportExpiry(ctx context.Context, expiresAt time.Time) {
+	if expiry, ok := ctx.Value(expiryKey{}).(*time.Time); ok {
+		*expiry = expiresAt
+	}
+}
+
+// getProviders returns the providers to consult, in order: the comma-separated names listed in
+// DOCKER_CREDENTIAL_ENV_PROVIDERS, or defaultProviderOrder.
+func getProviders() ([]Provider, error) {
+	names := defaultProviderOrder
+	if value := os.Getenv(envProviders); value != "" {
+		names = strings.Split(value, ",")
+	}
+
+	ordered := make([]Provider, 0, len(names))
+	for _, name := range names {
+		provider, ok := providerRegistry[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown provider %q in %s, expected one of: %s",
+				name, envProviders, strings.Join(slices.Sorted(maps.Keys(providerRegistry)), ", "))
+		}
+		ordered = append(ordered, provider)
+	}
+	return ordered, nil
+}
+
+// getProviderCredentials consults each matching provider in order, stopping at the first that finds
+// credentials or returns an error.
+// Returns the username, password, a boolean indicating if credentials were found, and any provider error.
+func getProviderCredentials(ctx context.Context, providers []Provider, host string) (username, password string, found bool, err error) {
+	trace := traceFromContext(ctx)
+	for _, provider := range providers {
+		if !provider.Match(host) {
+			trace.addProvider(provider, providerSkipped, nil)
+			continue
+		}
+		if username, password, found, err = provider.Get(ctx, host); err != nil {
+			trace.addProvider(provider, providerFailed, err)
+			return "", "", false, err
+		}
+		if !found {
+			trace.addProvider(provider, providerNotFound, nil)
+			continue
+		}
+		trace.addProvider(provider, providerFound, nil)
+		if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+			_, _ = fmt.Fprintf(os.Stderr, "Credentials for %q provided by %s\n", host, provider.Describe())
+		}
+		return username, password, true, nil
+	}
+	return "", "", false, nil
+}
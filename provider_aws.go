@@ -2,13 +2,22 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
 // ecrContext retrieves AWS credentials from environment variables
@@ -81,3 +90,205 @@ func (p *ecrContext) Retrieve(_ context.Context) (out aws.Credentials, err error
 	}
 	return out, nil
 }
+
+// ecrProvider exchanges AWS credentials for ECR login credentials.
+type ecrProvider struct{}
+
+// Match implements Provider.
+func (*ecrProvider) Match(host string) bool { return ecrHostname.MatchString(host) }
+
+// Get implements Provider.
+func (*ecrProvider) Get(ctx context.Context, host string) (username, password string, found bool, err error) {
+	submatches := ecrHostname.FindStringSubmatch(host)
+	provider := &ecrContext{
+		AccountID: submatches[ecrHostname.SubexpIndex("account")],
+		Region:    submatches[ecrHostname.SubexpIndex("region")],
+	}
+	if username, password, err = getEcrToken(ctx, provider); err != nil {
+		return "", "", false, err
+	}
+	return username, password, true, nil
+}
+
+// Describe implements Provider.
+func (*ecrProvider) Describe() string { return "AWS ECR token exchange" }
+
+// getEcrToken retrieves ECR authentication credentials (username and password) for the specified AWS account and hostname.
+// It uses AWS SDK configuration with a custom retry mechanism (10 attempts max, 5 second max backoff)
+// and a custom credentials provider that checks for account-specific environment variables.
+// The ECR authorization token is retrieved with a 30 second timeout, decoded from base64,
+// and split into username:password format. Debug mode will log token expiration time.
+//
+// Parameters:
+//
+//	ctx: The context bounding the request
+//	provider: The AWS account ID and region for the ECR repository
+//
+// Returns:
+//
+//	username: The decoded username (typically "AWS")
+//	password: The decoded password token
+//	err: Any error encountered during the process
+func getEcrToken(ctx context.Context, provider *ecrContext) (username, password string, err error) {
+	if provider == nil {
+		return "", "", errors.New("ecr: provider must not be nil")
+	}
+
+	// Set up the AWS SDK config with a custom retryer
+	simpleRetryer := func() aws.Retryer {
+		standardRetryer := retry.NewStandard(func(options *retry.StandardOptions) {
+			options.MaxAttempts = 10
+			options.MaxBackoff = time.Second * 5
+		})
+		return retry.AddWithMaxBackoffDelay(standardRetryer, time.Second)
+	}
+
+	trace := traceFromContext(ctx)
+	var extraOpts []func(*config.LoadOptions) error
+	if provider.HasAccountSuffixedCredentials() { // 1. Account-suffixed credentials
+		// Only use custom provider if account-suffixed access-key credentials exist
+		extraOpts = append(extraOpts, config.WithCredentialsProvider(aws.NewCredentialsCache(provider)))
+		trace.setAWSCredentials(envAwsAccessKeyID+"_"+provider.AccountID, "")
+	} else if profile := getProfile(provider.AccountID); profile != "" { // 2. Shared config profile
+		// If a profile is specified, use it to load the AWS configuration
+		if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+			_, _ = fmt.Fprintf(os.Stderr, "AWS profile %q (Account: %s)\n", profile, provider.AccountID)
+		}
+		extraOpts = append(extraOpts, config.WithSharedConfigProfile(profile))
+		trace.setAWSCredentials("shared config profile", profile)
+	} else {
+		trace.setAWSCredentials("default credential chain", "")
+	}
+
+	// If neither profile nor account-suffixed credentials, use default AWS credential chain
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+	cfg, err := config.LoadDefaultConfig(ctx,
+		append(extraOpts,
+			config.WithRetryer(simpleRetryer),
+			config.WithRegion(provider.Region))...)
+	if err != nil {
+		return username, password, err
+	}
+
+	// If a role ARN is specified for the account, assume that role
+	var roleArn string
+	if roleArn = getRoleArn(provider.AccountID, cfg.ConfigSources...); roleArn != "" {
+		trace.setAWSRoleARN(roleArn)
+		stsSvc := sts.NewFromConfig(cfg)
+		creds := stscreds.NewAssumeRoleProvider(stsSvc, roleArn)
+		cfg.Credentials = aws.NewCredentialsCache(creds)
+	}
+
+	client := ecr.NewFromConfig(cfg)
+
+	output, err := client.GetAuthorizationToken(ctx, nil)
+	if err != nil {
+		return username, password, err
+	}
+	for _, authData := range output.AuthorizationData {
+		if authData.ExpiresAt != nil {
+			reportExpiry(ctx, *authData.ExpiresAt)
+		}
+		if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+			if authData.ExpiresAt != nil {
+				expiration := authData.ExpiresAt.UTC().Format(time.RFC3339)
+				_, _ = fmt.Fprintf(os.Stderr, "ECR token for %q will expire at %s (UTC)\n", provider.AccountID, expiration)
+			}
+		}
+
+		if authData.AuthorizationToken == nil {
+			err = fmt.Errorf("ecr: authorization token for %q is nil", provider.AccountID)
+			return username, password, err
+		}
+
+		var tokenBytes []byte
+		tokenBytes, err = base64.StdEncoding.DecodeString(*authData.AuthorizationToken)
+		if err != nil {
+			return username, password, err
+		}
+		token := bytes.SplitN(tokenBytes, []byte{':'}, 2)
+		if len(token) != 2 {
+			err = fmt.Errorf("ecr: invalid authorization token format for %q", provider.AccountID)
+			return username, password, err
+		}
+
+		username, password = string(token[0]), string(token[1])
+	}
+	return username, password, err
+}
+
+// getProfile resolves an AWS profile name by checking, in order:
+// 1. Account-specific environment variable (AWS_PROFILE_<account>)
+// 2. Configuration sources (SharedConfigProfile or Profile)
+// 3. Default AWS_PROFILE environment variable
+//
+// Parameters:
+//
+//	account - AWS account ID to look up account-specific profile name
+//	configSources - Optional AWS configuration sources containing profile information
+//
+// Returns:
+//
+//	profile - Resolved AWS profile name, empty string if none found
+func getProfile(account string, configSources ...any) (profile string) {
+	// Check for account-specific profile environment variable
+	val, found := os.LookupEnv(envAwsProfile + "_" + account)
+	if found {
+		return strings.TrimSpace(val)
+	}
+
+	if len(configSources) == 0 {
+		return os.Getenv("AWS_PROFILE")
+	}
+
+	for _, x := range configSources {
+		switch impl := x.(type) {
+		case config.EnvConfig:
+			if impl.SharedConfigProfile != "" {
+				return strings.TrimSpace(impl.SharedConfigProfile)
+			}
+		case config.SharedConfig:
+			if impl.Profile != "" {
+				return strings.TrimSpace(impl.Profile)
+			}
+		}
+	}
+	return
+}
+
+// getRoleArn retrieves the AWS role ARN for a specific account by checking environment variables and AWS configurations.
+// It checks the account-specific role ARN environment variable (AWS_ROLE_ARN_<account>). If not found,
+// then checks the standard AWS role ARN environment variable (AWS_ROLE_ARN) when no config sources are provided.
+// Finally, checks config sources which may contain role ARNs in AWS environment config or shared config.
+// Returns role ARN string if found, empty string otherwise.
+func getRoleArn(account string, configSources ...any) (roleARN string) {
+	val, found := os.LookupEnv(envAwsRoleArn + "_" + account)
+	if found {
+		return strings.TrimSpace(val)
+	}
+
+	// Check if any account-specific AWS credentials exist
+	_, hasSuffixedEnv := os.LookupEnv(envAwsAccessKeyID + "_" + account)
+	if hasSuffixedEnv {
+		return ""
+	}
+
+	if len(configSources) == 0 {
+		return os.Getenv(envAwsRoleArn)
+	}
+
+	for _, x := range configSources {
+		switch impl := x.(type) {
+		case config.EnvConfig:
+			if impl.RoleARN != "" {
+				return strings.TrimSpace(impl.RoleARN)
+			}
+		case config.SharedConfig:
+			if impl.RoleARN != "" {
+				return strings.TrimSpace(impl.RoleARN)
+			}
+		}
+	}
+	return
+}
@@ -197,3 +197,98 @@ func TestECRContext_HasAccountSuffixedCredentials(t *testing.T) {
 		})
 	}
 }
+
+func TestGetRoleArn(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputEnv map[string]string
+		expected string
+	}{
+		{
+			name: "Standard environment variables",
+			inputEnv: map[string]string{
+				"AWS_ROLE_ARN": "arn:aws:iam::123456789012:role/my-role",
+			},
+			expected: "arn:aws:iam::123456789012:role/my-role",
+		},
+		{
+			name: "Suffixed environment variables",
+			inputEnv: map[string]string{
+				"AWS_ROLE_ARN_123456789012": "arn:aws:iam::123456789012:role/my-role",
+			},
+			expected: "arn:aws:iam::123456789012:role/my-role",
+		},
+		{
+			name: "Suffixed has higher priority",
+			inputEnv: map[string]string{
+				"AWS_ROLE_ARN":              "arn:aws:iam::123456789012:role/other-role",
+				"AWS_ROLE_ARN_123456789012": "arn:aws:iam::123456789012:role/my-role",
+			},
+			expected: "arn:aws:iam::123456789012:role/my-role",
+		},
+		{
+			name: "Suffixed variables set but role ARN set for standard environment",
+			inputEnv: map[string]string{
+				"AWS_ROLE_ARN":                       "arn:aws:iam::123456789012:role/my-role",
+				"AWS_ACCESS_KEY_ID_123456789012":     "AKIA...",
+				"AWS_SECRET_ACCESS_KEY_123456789012": "wJalr...",
+			},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.inputEnv {
+				t.Setenv(k, v)
+			}
+			actual := getRoleArn("123456789012")
+			if actual != tt.expected {
+				t.Errorf("GetRoleArn(<account_id>) actual = (%v), expected (%v)", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetProfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputEnv map[string]string
+		expected string
+	}{
+		{
+			name: "Standard environment variable",
+			inputEnv: map[string]string{
+				"AWS_PROFILE": "my-profile",
+			},
+			expected: "my-profile",
+		},
+		{
+			name: "Suffixed environment variable",
+			inputEnv: map[string]string{
+				"AWS_PROFILE_12345": "my-profile",
+			},
+			expected: "my-profile",
+		},
+		{
+			name: "Suffixed has higher priority",
+			inputEnv: map[string]string{
+				"AWS_PROFILE":       "other-profile",
+				"AWS_PROFILE_12345": "my-profile",
+			},
+			expected: "my-profile",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.inputEnv {
+				t.Setenv(k, v)
+			}
+			actual := getProfile("12345")
+			if actual != tt.expected {
+				t.Errorf("GetProfile(<suffix>) actual = (%v), expected (%v)", actual, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+// Package main provides Azure credential provider implementations.
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" // #nosec G505 -- x5t thumbprints are defined as SHA-1
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// acrUsername is the fixed username that accompanies an ACR refresh token,
+// matching the behaviour of `az acr login`.
+const acrUsername = "00000000-0000-0000-0000-000000000000"
+
+// azureCloud describes the endpoints of a single Azure cloud.
+type azureCloud struct {
+	AuthorityHost string
+	Scope         string
+}
+
+// azureClouds maps ACR domain suffixes to the Azure cloud that serves them.
+var azureClouds = map[string]azureCloud{
+	"io": {AuthorityHost: "https://login.microsoftonline.com", Scope: "https://management.azure.com/.default"},
+	"cn": {AuthorityHost: "https://login.chinacloudapi.cn", Scope: "https://management.chinacloudapi.cn/.default"},
+	"us": {AuthorityHost: "https://login.microsoftonline.us", Scope: "https://management.usgovcloudapi.net/.default"},
+}
+
+// acrContext retrieves Azure Container Registry refresh tokens by exchanging an
+// AAD access token obtained with service principal or workload identity
+// credentials from the environment:
+// - AZURE_CLIENT_ID and AZURE_TENANT_ID (required)
+// - AZURE_CLIENT_SECRET, or
+// - AZURE_CLIENT_CERTIFICATE_PATH (PEM certificate and unencrypted private key), or
+// - AZURE_FEDERATED_TOKEN_FILE (e.g. AKS workload identity).
+type acrContext struct {
+	Registry string
+	Cloud    azureCloud
+
+	httpClient *http.Client
+}
+
+// newAcrContext returns an acrContext for the given registry hostname and ACR domain suffix.
+func newAcrContext(registry, suffix string) *acrContext {
+	cloud, ok := azureClouds[suffix]
+	if !ok {
+		cloud = azureClouds["io"]
+	}
+	if authorityHost := os.Getenv(envAzureAuthorityHost); authorityHost != "" {
+		cloud.AuthorityHost = authorityHost
+	}
+	return &acrContext{
+		Registry:   registry,
+		Cloud:      cloud,
+		httpClient: http.DefaultClient,
+	}
+}
+
+// HasCredentials checks if the environment contains enough information to request an AAD token.
+func (p *acrContext) HasCredentials() bool {
+	if os.Getenv(envAzureClientID) == "" || os.Getenv(envAzureTenantID) == "" {
+		return false
+	}
+	for _, key := range []string{envAzureClientSecret, envAzureClientCertificatePath, envAzureFederatedTokenFile} {
+		if os.Getenv(key) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// getAcrToken exchanges an AAD access token for an ACR refresh token.
+// Returns the fixed ACR username and the refresh token as the password.
+func getAcrToken(ctx context.Context, provider *acrContext) (username, password string, err error) {
+	if provider == nil {
+		return "", "", errors.New("acr: provider must not be nil")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+
+	accessToken, err := provider.aadToken(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err := provider.exchange(ctx, accessToken)
+	if err != nil {
+		return "", "", err
+	}
+
+	expiresOn := jwtExpiry(refreshToken)
+	reportExpiry(ctx, expiresOn)
+	if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+		if !expiresOn.IsZero() {
+			expiration := expiresOn.UTC().Format(time.RFC3339)
+			_, _ = fmt.Fprintf(os.Stderr, "ACR refresh token for %q will expire at %s (UTC)\n", provider.Registry, expiration)
+		}
+	}
+
+	return acrUsername, refreshToken, nil
+}
+
+// jwtExpiry returns the unverified "exp" claim of a JWT, such as an ACR refresh token,
+// or the zero time if it cannot be decoded.
+func jwtExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
+
+// aadToken requests an AAD access token with the client-credentials grant.
+func (p *acrContext) aadToken(ctx context.Context) (string, error) {
+	clientID := os.Getenv(envAzureClientID)
+	tenantID := os.Getenv(envAzureTenantID)
+	tokenURL := strings.TrimSuffix(p.Cloud.AuthorityHost, "/") + "/" + url.PathEscape(tenantID) + "/oauth2/v2.0/token"
+
+	form := url.Values{
+		"grant_type": {"client_credentials"},
+		"client_id":  {clientID},
+		"scope":      {p.Cloud.Scope},
+	}
+
+	var source string
+	switch {
+	case os.Getenv(envAzureClientSecret) != "":
+		source = envAzureClientSecret
+		form.Set("client_secret", os.Getenv(envAzureClientSecret))
+	case os.Getenv(envAzureClientCertificatePath) != "":
+		source = envAzureClientCertificatePath
+		assertion, err := newClientAssertion(os.Getenv(envAzureClientCertificatePath), clientID, tokenURL)
+		if err != nil {
+			return "", err
+		}
+		form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+		form.Set("client_assertion", assertion)
+	case os.Getenv(envAzureFederatedTokenFile) != "":
+		source = envAzureFederatedTokenFile
+		assertion, err := os.ReadFile(os.Getenv(envAzureFederatedTokenFile))
+		if err != nil {
+			return "", fmt.Errorf("acr: failed to read federated token: %w", err)
+		}
+		form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+		form.Set("client_assertion", strings.TrimSpace(string(assertion)))
+	default:
+		return "", fmt.Errorf("acr: one of %s, %s or %s must be set", envAzureClientSecret, envAzureClientCertificatePath, envAzureFederatedTokenFile)
+	}
+
+	if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+		_, _ = fmt.Fprintf(os.Stderr, "Authenticating access to %q with AAD client %q via %s\n", p.Registry, clientID, source)
+	}
+
+	var response struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := p.postForm(ctx, tokenURL, form, &response); err != nil {
+		return "", fmt.Errorf("acr: aad token request failed: %w", err)
+	}
+	if response.AccessToken == "" {
+		return "", errors.New("acr: aad token response did not include an access token")
+	}
+	return response.AccessToken, nil
+}
+
+// exchange trades an AAD access token for an ACR refresh token via the registry's /oauth2/exchange endpoint.
+func (p *acrContext) exchange(ctx context.Context, accessToken string) (string, error) {
+	form := url.Values{
+		"grant_type":   {"access_token"},
+		"service":      {p.Registry},
+		"tenant":       {os.Getenv(envAzureTenantID)},
+		"access_token": {accessToken},
+	}
+
+	var response struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := p.postForm(ctx, defaultScheme+p.Registry+"/oauth2/exchange", form, &response); err != nil {
+		return "", fmt.Errorf("acr: token exchange with %q failed: %w", p.Registry, err)
+	}
+	if response.RefreshToken == "" {
+		return "", fmt.Errorf("acr: token exchange with %q did not return a refresh token", p.Registry)
+	}
+	return response.RefreshToken, nil
+}
+
+// postForm submits a form-encoded POST request and decodes the JSON response into out.
+func (p *acrContext) postForm(ctx context.Context, endpoint string, form url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := p.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// newClientAssertion builds an RS256-signed JWT client assertion from a PEM file
+// containing a certificate and its unencrypted private key.
+func newClientAssertion(path, clientID, audience string) (string, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is supplied by the operator
+	if err != nil {
+		return "", fmt.Errorf("acr: failed to read client certificate: %w", err)
+	}
+
+	var (
+		cert *x509.Certificate
+		key  crypto.Signer
+	)
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "CERTIFICATE":
+			if cert == nil {
+				if cert, err = x509.ParseCertificate(block.Bytes); err != nil {
+					return "", fmt.Errorf("acr: invalid client certificate: %w", err)
+				}
+			}
+		case "PRIVATE KEY":
+			parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return "", fmt.Errorf("acr: invalid client certificate key: %w", err)
+			}
+			signer, ok := parsed.(*rsa.PrivateKey)
+			if !ok {
+				return "", errors.New("acr: client certificate key must be RSA")
+			}
+			key = signer
+		case "RSA PRIVATE KEY":
+			if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+				return "", fmt.Errorf("acr: invalid client certificate key: %w", err)
+			}
+		}
+	}
+	if cert == nil || key == nil {
+		return "", fmt.Errorf("acr: %q must contain a PEM certificate and private key", path)
+	}
+
+	thumbprint := sha1.Sum(cert.Raw) // #nosec G401
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+		"x5t": base64.RawURLEncoding.EncodeToString(thumbprint[:]),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims, err := json.Marshal(map[string]any{
+		"aud": audience,
+		"iss": clientID,
+		"sub": clientID,
+		"jti": hex.EncodeToString(jti),
+		"nbf": now.Unix(),
+		"exp": now.Add(10 * time.Minute).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("acr: failed to sign client assertion: %w", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// acrProvider exchanges Azure credentials for Azure Container Registry refresh tokens.
+type acrProvider struct{}
+
+// Match implements Provider.
+func (*acrProvider) Match(host string) bool { return acrHostname.MatchString(host) }
+
+// Get implements Provider.
+func (*acrProvider) Get(ctx context.Context, host string) (username, password string, found bool, err error) {
+	// This is an Azure Container Registry: <registry>.azurecr.{io,cn,us}
+	submatches := acrHostname.FindStringSubmatch(host)
+	provider := newAcrContext(host, submatches[acrHostname.SubexpIndex("suffix")])
+	if !provider.HasCredentials() {
+		return "", "", false, nil
+	}
+	if username, password, err = getAcrToken(ctx, provider); err != nil {
+		return "", "", false, err
+	}
+	return username, password, true, nil
+}
+
+// Describe implements Provider.
+func (*acrProvider) Describe() string { return "Azure Container Registry token exchange" }
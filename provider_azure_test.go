@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestACRContext_HasCredentials(t *testing.T) {
+	useCases := []struct {
+		name     string
+		envVars  map[string]string
+		expected bool
+	}{
+		{
+			name: "Client secret",
+			envVars: map[string]string{
+				"AZURE_CLIENT_ID":     "client",
+				"AZURE_TENANT_ID":     "tenant",
+				"AZURE_CLIENT_SECRET": "secret",
+			},
+			expected: true,
+		},
+		{
+			name: "Federated token file",
+			envVars: map[string]string{
+				"AZURE_CLIENT_ID":            "client",
+				"AZURE_TENANT_ID":            "tenant",
+				"AZURE_FEDERATED_TOKEN_FILE": "/var/run/secrets/azure/tokens/azure-identity-token",
+			},
+			expected: true,
+		},
+		{
+			name: "Missing tenant",
+			envVars: map[string]string{
+				"AZURE_CLIENT_ID":     "client",
+				"AZURE_CLIENT_SECRET": "secret",
+			},
+			expected: false,
+		},
+		{
+			name: "Missing secret",
+			envVars: map[string]string{
+				"AZURE_CLIENT_ID": "client",
+				"AZURE_TENANT_ID": "tenant",
+			},
+			expected: false,
+		},
+	}
+
+	for _, tc := range useCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.envVars {
+				t.Setenv(k, v)
+			}
+
+			provider := newAcrContext("example.azurecr.io", "io")
+			if result := provider.HasCredentials(); result != tc.expected {
+				t.Errorf("expected %v but got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestNewAcrContext(t *testing.T) {
+	if p := newAcrContext("example.azurecr.cn", "cn"); p.Cloud.AuthorityHost != "https://login.chinacloudapi.cn" {
+		t.Errorf("unexpected authority host %q", p.Cloud.AuthorityHost)
+	}
+
+	t.Setenv("AZURE_AUTHORITY_HOST", "https://login.example.com")
+	if p := newAcrContext("example.azurecr.us", "us"); p.Cloud.AuthorityHost != "https://login.example.com" {
+		t.Errorf("unexpected authority host %q", p.Cloud.AuthorityHost)
+	}
+}
+
+func TestGetAcrToken(t *testing.T) {
+	certPath := writeTestCertificate(t)
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("federated-jwt\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	useCases := []struct {
+		name    string
+		envVars map[string]string
+		check   func(t *testing.T, r *http.Request)
+	}{
+		{
+			name:    "Client secret",
+			envVars: map[string]string{"AZURE_CLIENT_SECRET": "secret"},
+			check: func(t *testing.T, r *http.Request) {
+				if r.PostForm.Get("client_secret") != "secret" {
+					t.Errorf("unexpected client_secret %q", r.PostForm.Get("client_secret"))
+				}
+			},
+		},
+		{
+			name:    "Federated token file",
+			envVars: map[string]string{"AZURE_FEDERATED_TOKEN_FILE": tokenPath},
+			check: func(t *testing.T, r *http.Request) {
+				if r.PostForm.Get("client_assertion") != "federated-jwt" {
+					t.Errorf("unexpected client_assertion %q", r.PostForm.Get("client_assertion"))
+				}
+			},
+		},
+		{
+			name:    "Client certificate",
+			envVars: map[string]string{"AZURE_CLIENT_CERTIFICATE_PATH": certPath},
+			check: func(t *testing.T, r *http.Request) {
+				if parts := strings.Split(r.PostForm.Get("client_assertion"), "."); len(parts) != 3 {
+					t.Errorf("expected a signed JWT client assertion, got %q", r.PostForm.Get("client_assertion"))
+				}
+			},
+		},
+	}
+
+	expiresOn := time.Now().Add(3 * time.Hour).Truncate(time.Second)
+	claims := fmt.Sprintf(`{"exp":%d}`, expiresOn.Unix())
+	refreshToken := "e30." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".signature"
+
+	for _, tc := range useCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST /tenant/oauth2/v2.0/token", func(w http.ResponseWriter, r *http.Request) {
+				_ = r.ParseForm()
+				if r.PostForm.Get("client_id") != "client" {
+					t.Errorf("unexpected client_id %q", r.PostForm.Get("client_id"))
+				}
+				tc.check(t, r)
+				_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "aad-token"})
+			})
+			mux.HandleFunc("POST /oauth2/exchange", func(w http.ResponseWriter, r *http.Request) {
+				_ = r.ParseForm()
+				if r.PostForm.Get("access_token") != "aad-token" || r.PostForm.Get("tenant") != "tenant" {
+					http.Error(w, "bad exchange", http.StatusUnauthorized)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(map[string]string{"refresh_token": refreshToken})
+			})
+			server := httptest.NewTLSServer(mux)
+			defer server.Close()
+
+			t.Setenv("AZURE_CLIENT_ID", "client")
+			t.Setenv("AZURE_TENANT_ID", "tenant")
+			t.Setenv("AZURE_AUTHORITY_HOST", server.URL)
+			for k, v := range tc.envVars {
+				t.Setenv(k, v)
+			}
+
+			provider := newAcrContext(strings.TrimPrefix(server.URL, "https://"), "io")
+			provider.httpClient = server.Client()
+
+			ctx, expiry := withExpiry(context.Background())
+			username, password, err := getAcrToken(ctx, provider)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if username != acrUsername || password != refreshToken {
+				t.Errorf("getAcrToken() actual = (%v, %v), expected (%v, %v)", username, password, acrUsername, refreshToken)
+			}
+			if !expiry.Equal(expiresOn) {
+				t.Errorf("getAcrToken() reported expiry %v, expected %v", *expiry, expiresOn)
+			}
+		})
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate and RSA key to a PEM file.
+func writeTestCertificate(t *testing.T) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data []byte
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})...)
+
+	path := filepath.Join(t.TempDir(), "client.pem")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,105 @@
+// Package main provides mounted Docker config credential provider implementations.
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/docker/cli/cli/config/configfile"
+)
+
+// dockerConfigFilenames are the files loaded from directories listed in DOCKER_CREDENTIAL_ENV_DOCKERCONFIG_PATHS,
+// matching the key of a kubernetes.io/dockerconfigjson secret and the Docker client configuration file.
+var dockerConfigFilenames = []string{".dockerconfigjson", "config.json"}
+
+// getDockerConfigPaths expands DOCKER_CREDENTIAL_ENV_DOCKERCONFIG_PATHS into the list of files to load.
+// Entries are separated by the OS path list separator; directories are searched for dockerConfigFilenames.
+func getDockerConfigPaths() (paths []string) {
+	for _, entry := range filepath.SplitList(os.Getenv(envDockerConfigPaths)) {
+		if entry == "" {
+			continue
+		}
+		info, err := os.Stat(entry)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			paths = append(paths, entry)
+			continue
+		}
+		for _, name := range dockerConfigFilenames {
+			path := filepath.Join(entry, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return
+}
+
+// getDockerConfigCredentials retrieves credentials for the hostname from the auths of mounted
+// dockerconfigjson files, in the order listed. Credential stores and helpers configured in
+// those files are ignored.
+// Returns the username, password, a boolean indicating if credentials were found, and any parse error.
+func getDockerConfigCredentials(hostname string) (username, password string, found bool, err error) {
+	for _, path := range getDockerConfigPaths() {
+		config, err := loadDockerConfig(path)
+		if err != nil {
+			return "", "", false, err
+		}
+
+		auths := config.GetAuthConfigs()
+		for _, key := range slices.Sorted(maps.Keys(auths)) {
+			if authHostname, err := getHostname(key); err != nil || authHostname != hostname {
+				continue
+			}
+			auth := auths[key]
+			if auth.IdentityToken != "" {
+				auth.Username, auth.Password = identityTokenUsername, auth.IdentityToken
+			}
+			if auth.Username == "" && auth.Password == "" {
+				continue
+			}
+
+			if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+				_, _ = fmt.Fprintf(os.Stderr, "Authenticating access to %q with %q from %s\n", hostname, key, path)
+			}
+			return auth.Username, auth.Password, true, nil
+		}
+	}
+	return "", "", false, nil
+}
+
+// loadDockerConfig reads a Docker config file, decoding the base64 "auth" field of each entry.
+func loadDockerConfig(path string) (*configfile.ConfigFile, error) {
+	file, err := os.Open(path) // #nosec G304 -- path is supplied by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Docker config file %q: %w", path, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	config := configfile.New(path)
+	if err := config.LoadFromReader(file); err != nil {
+		return nil, fmt.Errorf("failed to parse Docker config file %q: %w", path, err)
+	}
+	return config, nil
+}
+
+// dockerConfigProvider provides credentials from mounted Docker config files.
+type dockerConfigProvider struct{}
+
+// Match implements Provider.
+func (*dockerConfigProvider) Match(string) bool { return os.Getenv(envDockerConfigPaths) != "" }
+
+// Get implements Provider.
+func (*dockerConfigProvider) Get(_ context.Context, host string) (username, password string, found bool, err error) {
+	return getDockerConfigCredentials(host)
+}
+
+// Describe implements Provider.
+func (*dockerConfigProvider) Describe() string { return "mounted Docker config files" }
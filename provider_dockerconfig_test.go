@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetDockerConfigCredentials(t *testing.T) {
+	// kubernetes.io/dockerconfigjson secret mounted as a directory
+	secretDir := t.TempDir()
+	writeFile(t, filepath.Join(secretDir, ".dockerconfigjson"), `{
+	"auths": {
+		"https://index.docker.io/v1/": {"auth": "dTE6cDE="},
+		"repo.example.com:5000": {"username": "u2", "password": "p2"}
+	}
+}`)
+
+	// Plain file with a credential store that must be ignored
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	writeFile(t, configFile, `{
+	"credsStore": "env",
+	"auths": {
+		"repo.example.com": {"username": "u3", "password": "p3"},
+		"other.example.com": {"username": "u4", "password": "p4"},
+		"token.example.com": {"identitytoken": "t5"}
+	}
+}`)
+
+	invalidFile := filepath.Join(t.TempDir(), "invalid.json")
+	writeFile(t, invalidFile, `{"auths": `)
+
+	type output struct {
+		username string
+		password string
+		found    bool
+		err      bool
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		paths    []string
+		expected output
+	}{
+		{
+			name:     "Encoded auth from directory",
+			input:    "docker.io",
+			paths:    []string{secretDir, configFile},
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name:     "First path wins",
+			input:    "repo.example.com",
+			paths:    []string{secretDir, configFile},
+			expected: output{username: "u2", password: "p2", found: true},
+		},
+		{
+			name:     "Second path",
+			input:    "other.example.com",
+			paths:    []string{secretDir, configFile},
+			expected: output{username: "u4", password: "p4", found: true},
+		},
+		{
+			name:     "Identity token",
+			input:    "token.example.com",
+			paths:    []string{configFile},
+			expected: output{username: "<token>", password: "t5", found: true},
+		},
+		{
+			name:     "No match",
+			input:    "example.net",
+			paths:    []string{secretDir, configFile},
+			expected: output{found: false},
+		},
+		{
+			name:     "Missing paths are skipped",
+			input:    "other.example.com",
+			paths:    []string{filepath.Join(secretDir, "missing"), configFile},
+			expected: output{username: "u4", password: "p4", found: true},
+		},
+		{
+			name:     "Invalid file",
+			input:    "repo.example.com",
+			paths:    []string{invalidFile},
+			expected: output{err: true},
+		},
+		{
+			name:     "Not configured",
+			input:    "repo.example.com",
+			paths:    nil,
+			expected: output{found: false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DOCKER_CREDENTIAL_ENV_DOCKERCONFIG_PATHS", strings.Join(tt.paths, string(os.PathListSeparator)))
+
+			actualUsername, actualPassword, actualFound, actualErr := getDockerConfigCredentials(tt.input)
+			if (actualErr != nil) != tt.expected.err {
+				t.Fatalf("getDockerConfigCredentials(%v) unexpected error state: %v", tt.input, actualErr)
+			}
+			if actualUsername != tt.expected.username || actualPassword != tt.expected.password || actualFound != tt.expected.found {
+				t.Errorf("getDockerConfigCredentials(%v) actual = (%v, %v, %v), expected (%v, %v, %v)", tt.input, actualUsername, actualPassword, actualFound, tt.expected.username, tt.expected.password, tt.expected.found)
+			}
+		})
+	}
+}
+
+// writeFile writes test data to path, failing the test on error.
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
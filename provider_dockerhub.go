@@ -0,0 +1,69 @@
+// Package main provides Docker Hub credential provider implementations.
+package main
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strings"
+)
+
+// dockerHubHostname is the canonical hostname for Docker Hub.
+const dockerHubHostname = "docker.io"
+
+// dockerHubHostnames lists every hostname under which Docker Hub is addressed.
+// Docker itself sends "https://index.docker.io/v1/" for Hub credentials.
+var dockerHubHostnames = []string{
+	dockerHubHostname,
+	"index.docker.io",
+	"registry-1.docker.io",
+	"registry.hub.docker.com",
+}
+
+// isDockerHub reports whether the hostname is one of the Docker Hub aliases.
+func isDockerHub(hostname string) bool {
+	return slices.Contains(dockerHubHostnames, hostname)
+}
+
+// getDockerHubCredentials retrieves Docker Hub credentials by checking, in order:
+// 1. Exact-match DOCKER_<alias>_* variables for each Docker Hub alias
+// 2. DOCKERHUB_USERNAME and DOCKERHUB_TOKEN
+//
+// Username and password values may be secret references.
+// Returns the username, password, a boolean indicating if credentials were found, and any lookup error.
+func getDockerHubCredentials(ctx context.Context) (username, password string, found bool, err error) {
+	scheme, err := getEnvScheme()
+	if err != nil {
+		return "", "", false, err
+	}
+
+	for _, alias := range dockerHubHostnames {
+		labels := strings.Split(alias, ".")
+		if username, password, found, err = findEnvCredentials(ctx, scheme, labels, 0); found || err != nil {
+			return
+		}
+	}
+
+	if username, found = os.LookupEnv(envDockerHubUsername); found {
+		if password, found = os.LookupEnv(envDockerHubToken); found {
+			username, password, err = resolveCredentials(ctx, username, password)
+			return username, password, err == nil, err
+		}
+	}
+
+	return "", "", false, nil
+}
+
+// dockerHubProvider provides Docker Hub credentials.
+type dockerHubProvider struct{}
+
+// Match implements Provider.
+func (*dockerHubProvider) Match(host string) bool { return isDockerHub(host) }
+
+// Get implements Provider.
+func (*dockerHubProvider) Get(ctx context.Context, _ string) (username, password string, found bool, err error) {
+	return getDockerHubCredentials(ctx)
+}
+
+// Describe implements Provider.
+func (*dockerHubProvider) Describe() string { return "Docker Hub variables" }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetDockerHubCredentials(t *testing.T) {
+	type output struct {
+		username string
+		password string
+		found    bool
+	}
+
+	tests := []struct {
+		name     string
+		inputEnv map[string]string
+		expected output
+	}{
+		{
+			name: "Canonical hostname",
+			inputEnv: map[string]string{
+				"DOCKER_docker_io_USR": "u1",
+				"DOCKER_docker_io_PSW": "p1",
+			},
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name: "Legacy index hostname",
+			inputEnv: map[string]string{
+				"DOCKER_index_docker_io_USR": "u1",
+				"DOCKER_index_docker_io_PSW": "p1",
+			},
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name: "Hyphenated alias",
+			inputEnv: map[string]string{
+				"DOCKER_registry_1_docker_io_USR": "u1",
+				"DOCKER_registry_1_docker_io_PSW": "p1",
+			},
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name: "DOCKERHUB variables",
+			inputEnv: map[string]string{
+				"DOCKERHUB_USERNAME": "u2",
+				"DOCKERHUB_TOKEN":    "p2",
+			},
+			expected: output{username: "u2", password: "p2", found: true},
+		},
+		{
+			name: "Alias variables have higher priority",
+			inputEnv: map[string]string{
+				"DOCKER_docker_io_USR": "u1",
+				"DOCKER_docker_io_PSW": "p1",
+				"DOCKERHUB_USERNAME":   "u2",
+				"DOCKERHUB_TOKEN":      "p2",
+			},
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name: "Incomplete DOCKERHUB variables",
+			inputEnv: map[string]string{
+				"DOCKERHUB_USERNAME": "u2",
+			},
+			expected: output{username: "", password: "", found: false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.inputEnv {
+				t.Setenv(k, v)
+			}
+			actualUsername, actualPassword, actualFound, actualErr := getDockerHubCredentials(context.Background())
+			if actualErr != nil {
+				t.Fatalf("getDockerHubCredentials() unexpected error: %v", actualErr)
+			}
+			if actualUsername != tt.expected.username || actualPassword != tt.expected.password || actualFound != tt.expected.found {
+				t.Errorf("getDockerHubCredentials() actual = (%v, %v, %v), expected (%v, %v, %v)", actualUsername, actualPassword, actualFound, tt.expected.username, tt.expected.password, tt.expected.found)
+			}
+		})
+	}
+}
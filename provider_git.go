@@ -0,0 +1,109 @@
+// Package main provides Git credential helper provider implementations.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gitCommand is the Git executable used for `git credential fill`.
+var gitCommand = "git"
+
+// gitCredentialTimeout bounds the time spent waiting for Git credential helpers.
+const gitCredentialTimeout = 10 * time.Second
+
+// gitCredentialEnabled reports whether the Git credential bridge has been enabled with DOCKER_CREDENTIAL_ENV_GIT_CREDENTIAL.
+func gitCredentialEnabled() bool {
+	b, err := strconv.ParseBool(os.Getenv(envGitCredential))
+	return err == nil && b
+}
+
+// getGitCredentials retrieves credentials for the hostname from the configured Git credential helpers
+// by running `git credential fill`. Terminal and askpass prompts are disabled, so a host without
+// stored credentials fails instead of blocking; this, and a missing Git executable, is reported as not found.
+// Returns the username, password, a boolean indicating if credentials were found, and any execution error.
+func getGitCredentials(ctx context.Context, hostname string) (username, password string, found bool, err error) {
+	gitPath, err := exec.LookPath(gitCommand)
+	if err != nil {
+		return "", "", false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, gitCredentialTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, gitPath, "-c", "core.askPass=", "-c", "credential.interactive=false", "credential", "fill") // #nosec G204
+	cmd.WaitDelay = commandWaitDelay
+	cmd.Stdin = strings.NewReader("protocol=https\nhost=" + hostname + "\n\n")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(withoutEnv(os.Environ(), "GIT_ASKPASS", "SSH_ASKPASS"), "GIT_TERMINAL_PROMPT=0")
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", "", false, fmt.Errorf("git credential: timed out after %s", gitCredentialTimeout)
+		}
+		if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+			_, _ = fmt.Fprintf(os.Stderr, "git credential fill for %q failed: %s\n", hostname, strings.TrimSpace(stderr.String()))
+		}
+		return "", "", false, nil
+	}
+
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "username":
+			username = value
+		case "password":
+			password = value
+		}
+	}
+	if username == "" || password == "" {
+		return "", "", false, nil
+	}
+
+	if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+		_, _ = fmt.Fprintf(os.Stderr, "Authenticating access to %q with Git credentials for %q\n", hostname, username)
+	}
+
+	return username, password, true, nil
+}
+
+// withoutEnv returns environ without the named variables.
+func withoutEnv(environ []string, names ...string) []string {
+	filtered := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if !slices.Contains(names, name) {
+			filtered = append(filtered, kv)
+		}
+	}
+	return filtered
+}
+
+// gitProvider provides credentials from git credential helpers, if enabled.
+type gitProvider struct{}
+
+// Match implements Provider.
+func (*gitProvider) Match(string) bool { return gitCredentialEnabled() }
+
+// Get implements Provider.
+func (*gitProvider) Get(ctx context.Context, host string) (username, password string, found bool, err error) {
+	return getGitCredentials(ctx, host)
+}
+
+// Describe implements Provider.
+func (*gitProvider) Describe() string { return "git credential helpers" }
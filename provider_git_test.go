@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeGitScript stands in for `git credential fill`, answering for git.example.com only
+// and refusing to run if prompts have not been disabled.
+const fakeGitScript = `#!/bin/sh
+[ "$GIT_TERMINAL_PROMPT" = "0" ] || exit 2
+[ -z "$GIT_ASKPASS" ] || exit 2
+while read -r line && [ -n "$line" ]; do
+	case "$line" in host=*) host="${line#host=}" ;; esac
+done
+if [ "$host" = "git.example.com" ]; then
+	printf 'protocol=https\nhost=%s\nusername=u1\npassword=p1\n' "$host"
+	exit 0
+fi
+echo "fatal: could not read Username for 'https://$host': terminal prompts disabled" >&2
+exit 128
+`
+
+func TestGetGitCredentials(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake git requires a POSIX shell")
+	}
+
+	fakeGit := filepath.Join(t.TempDir(), "git")
+	if err := os.WriteFile(fakeGit, []byte(fakeGitScript), 0700); err != nil { // #nosec G306
+		t.Fatal(err)
+	}
+
+	type output struct {
+		username string
+		password string
+		found    bool
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		command  string
+		expected output
+	}{
+		{
+			name:     "Stored credentials",
+			input:    "git.example.com",
+			command:  fakeGit,
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name:     "No stored credentials",
+			input:    "other.example.com",
+			command:  fakeGit,
+			expected: output{found: false},
+		},
+		{
+			name:     "Git not installed",
+			input:    "git.example.com",
+			command:  filepath.Join(t.TempDir(), "missing"),
+			expected: output{found: false},
+		},
+	}
+
+	t.Setenv("GIT_ASKPASS", "/usr/bin/false")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(command string) { gitCommand = command }(gitCommand)
+			gitCommand = tt.command
+
+			actualUsername, actualPassword, actualFound, actualErr := getGitCredentials(context.Background(), tt.input)
+			if actualErr != nil {
+				t.Fatalf("getGitCredentials(%v) unexpected error: %v", tt.input, actualErr)
+			}
+			if actualUsername != tt.expected.username || actualPassword != tt.expected.password || actualFound != tt.expected.found {
+				t.Errorf("getGitCredentials(%v) actual = (%v, %v, %v), expected (%v, %v, %v)", tt.input, actualUsername, actualPassword, actualFound, tt.expected.username, tt.expected.password, tt.expected.found)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+// Package main provides GitHub Container Registry credential provider implementations.
+package main
+
+import (
+	"context"
+	"os"
+)
+
+// ghcrUsername is the username GitHub expects alongside a token.
+const ghcrUsername = "x-access-token"
+
+// ghcrProvider provides GitHub Container Registry credentials from GITHUB_TOKEN.
+type ghcrProvider struct{}
+
+// Match implements Provider.
+func (*ghcrProvider) Match(host string) bool { return ghcrHostname.MatchString(host) }
+
+// Get implements Provider.
+// Token values may be secret references.
+func (*ghcrProvider) Get(ctx context.Context, _ string) (username, password string, found bool, err error) {
+	token, found := os.LookupEnv(envGitHubToken)
+	if !found {
+		return "", "", false, nil
+	}
+	if token, err = resolveSecretRef(ctx, token); err != nil {
+		return "", "", false, err
+	}
+	return ghcrUsername, token, true, nil
+}
+
+// Describe implements Provider.
+func (*ghcrProvider) Describe() string { return "GitHub Container Registry GITHUB_TOKEN" }
@@ -0,0 +1,88 @@
+// Package main provides GitLab CI credential provider implementations.
+package main
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// gitlabJobTokenUsername is the username GitLab expects alongside CI_JOB_TOKEN.
+const gitlabJobTokenUsername = "gitlab-ci-token"
+
+// getGitLabCredentials retrieves the job credentials that a GitLab CI runner exposes
+// for its container registry (CI_REGISTRY) and dependency proxy (CI_DEPENDENCY_PROXY_SERVER).
+// The server URL must match the respective server variable, including its port if it has one.
+// Returns the username, password, and a boolean indicating if credentials were found.
+func getGitLabCredentials(serverURL string) (username, password string, found bool) {
+	if matchesEnvServer(envGitLabRegistry, serverURL) {
+		username, password = os.Getenv(envGitLabRegistryUser), os.Getenv(envGitLabRegistryPassword)
+		if username != "" && password != "" {
+			return username, password, true
+		}
+		if token := os.Getenv(envGitLabJobToken); token != "" {
+			return gitlabJobTokenUsername, token, true
+		}
+	}
+
+	if matchesEnvServer(envGitLabDependencyProxyServer, serverURL) {
+		username, password = os.Getenv(envGitLabDependencyProxyUser), os.Getenv(envGitLabDependencyProxyPassword)
+		if username != "" && password != "" {
+			return username, password, true
+		}
+	}
+
+	return "", "", false
+}
+
+// matchesEnvHostname reports whether the server named by the environment variable key
+// (e.g. "registry.gitlab.com" or "gitlab.example.com:5050") has the given hostname.
+func matchesEnvHostname(key, hostname string) bool {
+	server := os.Getenv(key)
+	if server == "" {
+		return false
+	}
+	serverHostname, err := getHostname(server)
+	if err != nil {
+		return false
+	}
+	return serverHostname == hostname
+}
+
+// matchesEnvServer reports whether the server named by the environment variable key is the server URL's host.
+// If the variable includes a port (other than the default 443), the server URL must have the same port, so that
+// credentials for "gitlab.example.com:5050" are not sent to other services on the same host.
+func matchesEnvServer(key, serverURL string) bool {
+	hostname, err := getHostname(serverURL)
+	if err != nil || !matchesEnvHostname(key, hostname) {
+		return false
+	}
+	return getServerPort(os.Getenv(key)) == getServerPort(serverURL)
+}
+
+// getServerPort returns the explicit port of a server URL, or "" if it has none or uses the default HTTPS port.
+func getServerPort(serverURL string) string {
+	server, err := url.Parse(defaultScheme + strings.TrimPrefix(serverURL, defaultScheme))
+	if err != nil || server.Port() == "443" {
+		return ""
+	}
+	return server.Port()
+}
+
+// gitlabProvider provides GitLab CI job credentials.
+type gitlabProvider struct{}
+
+// Match implements Provider.
+func (*gitlabProvider) Match(host string) bool {
+	return matchesEnvHostname(envGitLabRegistry, host) || matchesEnvHostname(envGitLabDependencyProxyServer, host)
+}
+
+// Get implements Provider.
+func (*gitlabProvider) Get(ctx context.Context, host string) (username, password string, found bool, err error) {
+	username, password, found = getGitLabCredentials(serverURLFromContext(ctx, host))
+	return username, password, found, nil
+}
+
+// Describe implements Provider.
+func (*gitlabProvider) Describe() string { return "GitLab CI variables" }
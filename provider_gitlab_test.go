@@ -0,0 +1,139 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestGetGitLabCredentials(t *testing.T) {
+	type output struct {
+		username string
+		password string
+		found    bool
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		inputEnv map[string]string
+		expected output
+	}{
+		{
+			name:  "Registry credentials",
+			input: "registry.gitlab.com",
+			inputEnv: map[string]string{
+				"CI_REGISTRY":          "registry.gitlab.com",
+				"CI_REGISTRY_USER":     "gitlab-ci-token",
+				"CI_REGISTRY_PASSWORD": "p1",
+			},
+			expected: output{username: "gitlab-ci-token", password: "p1", found: true},
+		},
+		{
+			name:  "Registry with port",
+			input: "https://gitlab.example.com:5050",
+			inputEnv: map[string]string{
+				"CI_REGISTRY":          "gitlab.example.com:5050",
+				"CI_REGISTRY_USER":     "u1",
+				"CI_REGISTRY_PASSWORD": "p1",
+			},
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name:  "Registry with port requires port",
+			input: "gitlab.example.com",
+			inputEnv: map[string]string{
+				"CI_REGISTRY":  "gitlab.example.com:5050",
+				"CI_JOB_TOKEN": "t1",
+			},
+			expected: output{username: "", password: "", found: false},
+		},
+		{
+			name:  "Registry with port requires same port",
+			input: "gitlab.example.com:5051",
+			inputEnv: map[string]string{
+				"CI_REGISTRY":  "gitlab.example.com:5050",
+				"CI_JOB_TOKEN": "t1",
+			},
+			expected: output{username: "", password: "", found: false},
+		},
+		{
+			name:  "Job token fallback",
+			input: "registry.gitlab.com",
+			inputEnv: map[string]string{
+				"CI_REGISTRY":  "registry.gitlab.com",
+				"CI_JOB_TOKEN": "t1",
+			},
+			expected: output{username: "gitlab-ci-token", password: "t1", found: true},
+		},
+		{
+			name:  "Dependency proxy",
+			input: "gitlab.com",
+			inputEnv: map[string]string{
+				"CI_REGISTRY":                  "registry.gitlab.com",
+				"CI_REGISTRY_USER":             "u1",
+				"CI_REGISTRY_PASSWORD":         "p1",
+				"CI_DEPENDENCY_PROXY_SERVER":   "gitlab.com:443",
+				"CI_DEPENDENCY_PROXY_USER":     "u2",
+				"CI_DEPENDENCY_PROXY_PASSWORD": "p2",
+			},
+			expected: output{username: "u2", password: "p2", found: true},
+		},
+		{
+			name:  "Different registry",
+			input: "ghcr.io",
+			inputEnv: map[string]string{
+				"CI_REGISTRY":          "registry.gitlab.com",
+				"CI_REGISTRY_USER":     "u1",
+				"CI_REGISTRY_PASSWORD": "p1",
+			},
+			expected: output{username: "", password: "", found: false},
+		},
+		{
+			name:     "Not in GitLab CI",
+			input:    "registry.gitlab.com",
+			inputEnv: map[string]string{},
+			expected: output{username: "", password: "", found: false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.inputEnv {
+				t.Setenv(k, v)
+			}
+			actualUsername, actualPassword, actualFound := getGitLabCredentials(tt.input)
+			if actualUsername != tt.expected.username || actualPassword != tt.expected.password || actualFound != tt.expected.found {
+				t.Errorf("getGitLabCredentials(%v) actual = (%v, %v, %v), expected (%v, %v, %v)", tt.input, actualUsername, actualPassword, actualFound, tt.expected.username, tt.expected.password, tt.expected.found)
+			}
+		})
+	}
+}
+
+func TestGitLabProvider_Match(t *testing.T) {
+	t.Setenv("CI_REGISTRY", "gitlab.example.com:5050")
+	t.Setenv("CI_DEPENDENCY_PROXY_SERVER", "gitlab.example.com:443")
+
+	provider := &gitlabProvider{}
+	for host, expected := range map[string]bool{
+		"gitlab.example.com": true,
+		"ghcr.io":            false,
+		"example.com":        false,
+	} {
+		if actual := provider.Match(host); actual != expected {
+			t.Errorf("Match(%v) actual = %v, expected %v", host, actual, expected)
+		}
+	}
+}
+
+func TestEnvGet_GitLab(t *testing.T) {
+	t.Setenv("CI_REGISTRY", "gitlab.example.com:5050")
+	t.Setenv("CI_JOB_TOKEN", "t1")
+
+	e := Env{}
+
+	if username, password, err := e.Get("https://gitlab.example.com:5050"); err != nil || username != "gitlab-ci-token" || password != "t1" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (gitlab-ci-token, t1, <nil>)", username, password, err)
+	}
+	if username, password, err := e.Get("https://gitlab.example.com"); err != nil || username != "" || password != "" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (, , <nil>)", username, password, err)
+	}
+}
@@ -0,0 +1,227 @@
+// Package main provides netrc credential provider implementations.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// netrcEntry is a single machine or default entry of a netrc file.
+type netrcEntry struct {
+	Machine  string // empty for the default entry
+	Login    string
+	Password string
+}
+
+// netrcEnabled reports whether the netrc fallback has been enabled with DOCKER_CREDENTIAL_ENV_NETRC.
+func netrcEnabled() bool {
+	b, err := strconv.ParseBool(os.Getenv(envNetrc))
+	return err == nil && b
+}
+
+// getNetrcPath returns $NETRC, or ~/.netrc (~/_netrc on Windows).
+func getNetrcPath() (string, error) {
+	if path := os.Getenv(envNetrcPath); path != "" {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		name = "_netrc"
+	}
+	return filepath.Join(homeDir, name), nil
+}
+
+// getNetrcCredentials retrieves credentials for the server URL from the netrc file.
+// A machine entry that includes a port (e.g. "registry.example.com:5000") only matches that port, and is
+// preferred over a machine entry without a port. The default entry matches any server.
+// A missing netrc file is not an error.
+// Returns the username, password, a boolean indicating if credentials were found, and any parse error.
+func getNetrcCredentials(serverURL string) (username, password string, found bool, err error) {
+	hostname, port, err := getNetrcHost(serverURL)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	path, err := getNetrcPath()
+	if err != nil {
+		return "", "", false, nil
+	}
+	file, err := os.Open(path) // #nosec G304 -- path is supplied by the operator
+	if errors.Is(err, os.ErrNotExist) {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, fmt.Errorf("netrc: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	entries, err := parseNetrc(file)
+	if err != nil {
+		return "", "", false, fmt.Errorf("netrc: %s: %w", path, err)
+	}
+
+	var hostMatch, defaultMatch *netrcEntry
+	for i := range entries {
+		entry := &entries[i]
+		if entry.Machine == "" {
+			defaultMatch = entry
+			continue
+		}
+		machineHostname, machinePort, err := getNetrcHost(entry.Machine)
+		if err != nil || machineHostname != hostname {
+			continue
+		}
+		if machinePort != "" {
+			if machinePort == port {
+				return entry.Login, entry.Password, true, nil
+			}
+			continue
+		}
+		if hostMatch == nil {
+			hostMatch = entry
+		}
+	}
+
+	switch {
+	case hostMatch != nil:
+		return hostMatch.Login, hostMatch.Password, true, nil
+	case defaultMatch != nil:
+		return defaultMatch.Login, defaultMatch.Password, true, nil
+	default:
+		return "", "", false, nil
+	}
+}
+
+// getNetrcHost returns the normalised hostname and explicit port (if any) of a server URL or netrc machine name.
+func getNetrcHost(server string) (hostname, port string, err error) {
+	hostname, err = getHostname(server)
+	if err != nil {
+		return "", "", err
+	}
+	parsed, err := url.Parse(defaultScheme + strings.TrimPrefix(server, defaultScheme))
+	if err != nil {
+		return "", "", err
+	}
+	return hostname, parsed.Port(), nil
+}
+
+// netrcValueKeywords are the netrc keywords followed by a value.
+var netrcValueKeywords = map[string]bool{"machine": true, "login": true, "password": true, "account": true, "macdef": true}
+
+// parseNetrc parses netrc entries. Comments, starting with '#' at the start of a line or in place of a keyword,
+// and macdef definitions are skipped.
+// An error is returned for tokens that are missing their value, for login, password or account tokens
+// outside of an entry, for unknown tokens, and for entries following the default entry.
+func parseNetrc(r io.Reader) (entries []netrcEntry, err error) {
+	var (
+		tokens  []string
+		lineNos []int
+	)
+
+	scanner := bufio.NewScanner(r)
+	inMacro, expectsValue := false, false
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if inMacro {
+			// A macro definition ends at the first empty line
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		for i, field := range strings.Fields(line) {
+			// A comment starts with '#' at the start of a line or in place of a keyword; values may contain or start with '#'
+			if strings.HasPrefix(field, "#") && (i == 0 || !expectsValue) {
+				break
+			}
+			tokens = append(tokens, field)
+			lineNos = append(lineNos, lineNo)
+			expectsValue = !expectsValue && netrcValueKeywords[field]
+		}
+		// macdef consumes the remainder of its line and the following lines
+		if n := len(tokens); n >= 2 && tokens[n-2] == "macdef" {
+			tokens, lineNos = tokens[:n-2], lineNos[:n-2]
+			inMacro = true
+		} else if n >= 1 && tokens[n-1] == "macdef" {
+			return nil, fmt.Errorf("line %d: macdef without a name", lineNo)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var (
+		current    *netrcEntry
+		hasDefault bool
+	)
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+
+		value := func() (string, error) {
+			if i+1 >= len(tokens) {
+				return "", fmt.Errorf("line %d: %q is missing a value", lineNos[i], token)
+			}
+			i++
+			return tokens[i], nil
+		}
+
+		switch token {
+		case "machine", "default":
+			if hasDefault {
+				return nil, fmt.Errorf("line %d: %q follows the default entry", lineNos[i], token)
+			}
+			entries = append(entries, netrcEntry{})
+			current = &entries[len(entries)-1]
+			if token == "default" {
+				hasDefault = true
+				continue
+			}
+			if current.Machine, err = value(); err != nil {
+				return nil, err
+			}
+		case "login", "password", "account":
+			if current == nil {
+				return nil, fmt.Errorf("line %d: %q outside of a machine entry", lineNos[i], token)
+			}
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			switch token {
+			case "login":
+				current.Login = v
+			case "password":
+				current.Password = v
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unexpected token %q", lineNos[i], token)
+		}
+	}
+
+	return entries, nil
+}
+
+// netrcProvider provides credentials from the netrc file, if enabled.
+type netrcProvider struct{}
+
+// Match implements Provider.
+func (*netrcProvider) Match(string) bool { return netrcEnabled() }
+
+// Get implements Provider.
+func (*netrcProvider) Get(ctx context.Context, host string) (username, password string, found bool, err error) {
+	return getNetrcCredentials(serverURLFromContext(ctx, host))
+}
+
+// Describe implements Provider.
+func (*netrcProvider) Describe() string { return "netrc file" }
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseNetrc(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []netrcEntry
+		errContains string
+	}{
+		{
+			name:  "Single line entries",
+			input: "machine a.example.com login u1 password p1\nmachine b.example.com login u2 password p2\n",
+			expected: []netrcEntry{
+				{Machine: "a.example.com", Login: "u1", Password: "p1"},
+				{Machine: "b.example.com", Login: "u2", Password: "p2"},
+			},
+		},
+		{
+			name:  "Multi-line entries with comments, account and default",
+			input: "# registries\nmachine a.example.com\n  login u1 # robot\n  account ignored\n  password p1\ndefault login u0 password p0\n",
+			expected: []netrcEntry{
+				{Machine: "a.example.com", Login: "u1", Password: "p1"},
+				{Login: "u0", Password: "p0"},
+			},
+		},
+		{
+			name:  "Macro definitions are skipped",
+			input: "macdef init\ncd /pub\nmachine inside.macro login x password y\n\nmachine a.example.com login u1 password p1\n",
+			expected: []netrcEntry{
+				{Machine: "a.example.com", Login: "u1", Password: "p1"},
+			},
+		},
+		{
+			name:     "Empty file",
+			input:    "",
+			expected: nil,
+		},
+		{
+			name:  "Hash in values",
+			input: "machine a.example.com login u#1 password p#ss\nmachine b.example.com login u2 password #abc # comment\n",
+			expected: []netrcEntry{
+				{Machine: "a.example.com", Login: "u#1", Password: "p#ss"},
+				{Machine: "b.example.com", Login: "u2", Password: "#abc"},
+			},
+		},
+		{
+			name:        "Comment line in place of a value",
+			input:       "machine a.example.com login u1 password\n# p1\n",
+			errContains: `line 1: "password" is missing a value`,
+		},
+		{
+			name:        "Missing machine name",
+			input:       "machine",
+			errContains: `line 1: "machine" is missing a value`,
+		},
+		{
+			name:        "Missing password value",
+			input:       "machine a.example.com login u1\npassword",
+			errContains: `line 2: "password" is missing a value`,
+		},
+		{
+			name:        "Login outside of an entry",
+			input:       "login u1 password p1\n",
+			errContains: `line 1: "login" outside of a machine entry`,
+		},
+		{
+			name:        "Unexpected token",
+			input:       "machine a.example.com user u1\n",
+			errContains: `line 1: unexpected token "user"`,
+		},
+		{
+			name:        "Entry after default",
+			input:       "default login u0 password p0\nmachine a.example.com login u1 password p1\n",
+			errContains: `line 2: "machine" follows the default entry`,
+		},
+		{
+			name:        "Macro without name",
+			input:       "macdef\n",
+			errContains: "line 1: macdef without a name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := parseNetrc(strings.NewReader(tt.input))
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("Expected error to contain %q, but got %v", tt.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(actual) != len(tt.expected) {
+				t.Fatalf("parseNetrc() actual = %v, expected %v", actual, tt.expected)
+			}
+			for i := range actual {
+				if actual[i] != tt.expected[i] {
+					t.Errorf("parseNetrc()[%d] actual = %v, expected %v", i, actual[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetNetrcCredentials(t *testing.T) {
+	type output struct {
+		username string
+		password string
+		found    bool
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		netrc    string
+		expected output
+	}{
+		{
+			name:     "Machine match",
+			input:    "https://repo.example.com",
+			netrc:    "machine repo.example.com login u1 password p1\n",
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name:     "Port-specific machine preferred",
+			input:    "repo.example.com:5000",
+			netrc:    "machine repo.example.com login u1 password p1\nmachine repo.example.com:5000 login u2 password p2\n",
+			expected: output{username: "u2", password: "p2", found: true},
+		},
+		{
+			name:     "Port-specific machine requires port",
+			input:    "repo.example.com",
+			netrc:    "machine repo.example.com:5000 login u2 password p2\n",
+			expected: output{found: false},
+		},
+		{
+			name:     "Port-specific machine requires same port",
+			input:    "repo.example.com:5001",
+			netrc:    "machine repo.example.com:5000 login u2 password p2\nmachine repo.example.com login u1 password p1\n",
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name:     "Docker Hub alias",
+			input:    "https://index.docker.io/v1/",
+			netrc:    "machine registry-1.docker.io login u1 password p1\n",
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name:     "Default",
+			input:    "other.example.com",
+			netrc:    "machine repo.example.com login u1 password p1\ndefault login u0 password p0\n",
+			expected: output{username: "u0", password: "p0", found: true},
+		},
+		{
+			name:     "Hash in password",
+			input:    "repo.example.com",
+			netrc:    "# registries\nmachine repo.example.com login u1 password p#ss # robot\n",
+			expected: output{username: "u1", password: "p#ss", found: true},
+		},
+		{
+			name:     "No match",
+			input:    "other.example.com",
+			netrc:    "machine repo.example.com login u1 password p1\n",
+			expected: output{found: false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "netrc")
+			writeFile(t, path, tt.netrc)
+			t.Setenv("NETRC", path)
+
+			actualUsername, actualPassword, actualFound, actualErr := getNetrcCredentials(tt.input)
+			if actualErr != nil {
+				t.Fatalf("getNetrcCredentials(%v) unexpected error: %v", tt.input, actualErr)
+			}
+			if actualUsername != tt.expected.username || actualPassword != tt.expected.password || actualFound != tt.expected.found {
+				t.Errorf("getNetrcCredentials(%v) actual = (%v, %v, %v), expected (%v, %v, %v)", tt.input, actualUsername, actualPassword, actualFound, tt.expected.username, tt.expected.password, tt.expected.found)
+			}
+		})
+	}
+
+	t.Run("Missing file", func(t *testing.T) {
+		t.Setenv("NETRC", filepath.Join(t.TempDir(), "missing"))
+		if _, _, found, err := getNetrcCredentials("repo.example.com"); found || err != nil {
+			t.Errorf("getNetrcCredentials() actual = (%v, %v), expected (false, <nil>)", found, err)
+		}
+	})
+
+	t.Run("Malformed file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "netrc")
+		writeFile(t, path, "machine repo.example.com login\n")
+		t.Setenv("NETRC", path)
+		if _, _, _, err := getNetrcCredentials("repo.example.com"); err == nil {
+			t.Error("expected an error but got none")
+		}
+	})
+}
+
+func TestEnvGet_Netrc(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netrc")
+	writeFile(t, path, "machine repo.example.com login u1 password p1\n")
+	t.Setenv("NETRC", path)
+	t.Setenv("DOCKER_other_example_com_USR", "u2")
+	t.Setenv("DOCKER_other_example_com_PSW", "p2")
+
+	e := Env{}
+
+	// Disabled by default
+	if username, password, err := e.Get("https://repo.example.com"); err != nil || username != "" || password != "" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (, , <nil>)", username, password, err)
+	}
+
+	t.Setenv("DOCKER_CREDENTIAL_ENV_NETRC", "true")
+	if username, password, err := e.Get("https://repo.example.com"); err != nil || username != "u1" || password != "p1" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (u1, p1, <nil>)", username, password, err)
+	}
+
+	// Environment variables take precedence over netrc
+	if username, password, err := e.Get("https://other.example.com"); err != nil || username != "u2" || password != "p2" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (u2, p2, <nil>)", username, password, err)
+	}
+}
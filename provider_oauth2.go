@@ -0,0 +1,164 @@
+// Package main provides OAuth2 client-credentials provider implementations.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// oauth2Client mints bearer tokens with the OAuth2 client-credentials grant,
+// configured from the environment variables:
+// - DOCKER_<hostname>_CLIENT_ID
+// - DOCKER_<hostname>_CLIENT_SECRET
+// - DOCKER_<hostname>_TOKEN_URL
+// - DOCKER_<hostname>_SCOPE (optional)
+// - DOCKER_<hostname>_USR (optional, defaults to the client ID).
+type oauth2Client struct {
+	ClientID     string
+	ClientSecret string
+	TokenURL     string
+	Scope        string
+	Username     string
+
+	httpClient *http.Client
+}
+
+// oauth2Token is the cached form of a token response.
+type oauth2Token struct {
+	AccessToken string    `json:"access_token"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+// cacheExpiry implements cacheable. Tokens without an access token are never cached.
+func (t oauth2Token) cacheExpiry() time.Time {
+	if t.AccessToken == "" {
+		return time.Time{}
+	}
+	return t.Expiry
+}
+
+// getOAuth2Client returns an oauth2Client if all mandatory variables exist in the scheme for the labels at the given offset.
+// Returns nil if any of the client ID, client secret or token URL is missing.
+func getOAuth2Client(scheme envScheme, labels []string, offset int) *oauth2Client {
+	clientID, hasClientID := lookupEnvVariable(getEnvVariable(scheme, labels, offset, envClientIDSuffix))
+	clientSecret, hasClientSecret := lookupEnvVariable(getEnvVariable(scheme, labels, offset, envClientSecretSuffix))
+	tokenURL, hasTokenURL := lookupEnvVariable(getEnvVariable(scheme, labels, offset, envTokenURLSuffix))
+	if !hasClientID || !hasClientSecret || !hasTokenURL {
+		return nil
+	}
+
+	username := clientID
+	for _, suffixes := range scheme.Credentials {
+		if value, found := lookupEnvVariable(getEnvVariable(scheme, labels, offset, suffixes.Username)); found {
+			username = value
+			break
+		}
+	}
+
+	scope, _ := lookupEnvVariable(getEnvVariable(scheme, labels, offset, envScopeSuffix))
+
+	return &oauth2Client{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     tokenURL,
+		Scope:        scope,
+		Username:     username,
+		httpClient:   http.DefaultClient,
+	}
+}
+
+// Credentials returns the configured username and a valid access token,
+// reusing a cached token until it expires.
+func (c *oauth2Client) Credentials(ctx context.Context) (username, password string, err error) {
+	cachePath := c.cachePath()
+
+	if token, ok := readCache[oauth2Token](cachePath); ok {
+		reportExpiry(ctx, token.Expiry)
+		return c.Username, token.AccessToken, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+
+	token, err := c.requestToken(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	reportExpiry(ctx, token.Expiry)
+	if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+		if !token.Expiry.IsZero() {
+			expiration := token.Expiry.UTC().Format(time.RFC3339)
+			_, _ = fmt.Fprintf(os.Stderr, "OAuth2 token for %q will expire at %s (UTC)\n", c.ClientID, expiration)
+		}
+	}
+
+	writeCache(cachePath, *token)
+
+	return c.Username, token.AccessToken, nil
+}
+
+// requestToken performs the client-credentials token request.
+func (c *oauth2Client) requestToken(ctx context.Context) (*oauth2Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if c.Scope != "" {
+		form.Set("scope", c.Scope)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+
+	client := c.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: token request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: token request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oauth2: token request to %q failed: unexpected status %s", c.TokenURL, resp.Status)
+	}
+
+	var response struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("oauth2: invalid token response: %w", err)
+	}
+	if response.AccessToken == "" {
+		return nil, errors.New("oauth2: token response did not include an access token")
+	}
+
+	token := &oauth2Token{AccessToken: response.AccessToken}
+	if response.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(response.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// cachePath returns the token cache file for this client, keyed on every request parameter.
+func (c *oauth2Client) cachePath() string {
+	return cachePath("oauth2", c.TokenURL, c.ClientID, c.ClientSecret, c.Scope)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newOAuth2TestServer returns a token endpoint that counts the tokens it issues.
+func newOAuth2TestServer(t *testing.T, expiresIn int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok || clientID != "client" || clientSecret != "secret" {
+			http.Error(w, "invalid_client", http.StatusUnauthorized)
+			return
+		}
+		_ = r.ParseForm()
+		if r.PostForm.Get("grant_type") != "client_credentials" {
+			http.Error(w, "unsupported_grant_type", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "token-" + r.PostForm.Get("scope"),
+			"token_type":   "Bearer",
+			"expires_in":   expiresIn,
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func TestGetEnvCredentials_OAuth2(t *testing.T) {
+	server, requests := newOAuth2TestServer(t, 3600)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	t.Setenv("DOCKER_example_com_CLIENT_ID", "client")
+	t.Setenv("DOCKER_example_com_CLIENT_SECRET", "secret")
+	t.Setenv("DOCKER_example_com_TOKEN_URL", server.URL)
+	t.Setenv("DOCKER_example_com_SCOPE", "registry")
+	t.Setenv("DOCKER_static_example_com_USR", "u1")
+	t.Setenv("DOCKER_static_example_com_PSW", "p1")
+	t.Setenv("DOCKER_named_example_com_CLIENT_ID", "client")
+	t.Setenv("DOCKER_named_example_com_CLIENT_SECRET", "secret")
+	t.Setenv("DOCKER_named_example_com_TOKEN_URL", server.URL)
+	t.Setenv("DOCKER_named_example_com_USR", "robot")
+
+	type output struct {
+		username string
+		password string
+		found    bool
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected output
+	}{
+		{
+			name:     "Exact match",
+			input:    "example.com",
+			expected: output{username: "client", password: "token-registry", found: true},
+		},
+		{
+			name:     "Subdomain",
+			input:    "repo.example.com",
+			expected: output{username: "client", password: "token-registry", found: true},
+		},
+		{
+			name:     "Static credentials are more specific",
+			input:    "static.example.com",
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name:     "Configured username",
+			input:    "named.example.com",
+			expected: output{username: "robot", password: "token-", found: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actualUsername, actualPassword, actualFound, actualErr := getEnvCredentials(context.Background(), tt.input)
+			if actualErr != nil {
+				t.Fatalf("getEnvCredentials(%v) unexpected error: %v", tt.input, actualErr)
+			}
+			if actualUsername != tt.expected.username || actualPassword != tt.expected.password || actualFound != tt.expected.found {
+				t.Errorf("getEnvCredentials(%v) actual = (%v, %v, %v), expected (%v, %v, %v)", tt.input, actualUsername, actualPassword, actualFound, tt.expected.username, tt.expected.password, tt.expected.found)
+			}
+		})
+	}
+
+	// example.com and repo.example.com share a client, so only two tokens should have been issued
+	if actual := requests.Load(); actual != 2 {
+		t.Errorf("expected 2 token requests, got %d", actual)
+	}
+}
+
+func TestOAuth2Client_Credentials(t *testing.T) {
+	t.Run("Short-lived tokens are not reused", func(t *testing.T) {
+		server, requests := newOAuth2TestServer(t, 10)
+		t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+		client := &oauth2Client{ClientID: "client", ClientSecret: "secret", TokenURL: server.URL, Username: "client"}
+		for range 2 {
+			if _, _, err := client.Credentials(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if actual := requests.Load(); actual != 2 {
+			t.Errorf("expected 2 token requests, got %d", actual)
+		}
+	})
+
+	t.Run("Expiry is reported for fresh and cached tokens", func(t *testing.T) {
+		server, requests := newOAuth2TestServer(t, 3600)
+		t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+		client := &oauth2Client{ClientID: "client", ClientSecret: "secret", TokenURL: server.URL, Username: "client"}
+		for range 2 {
+			ctx, expiry := withExpiry(context.Background())
+			if _, _, err := client.Credentials(ctx); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if remaining := time.Until(*expiry); remaining < 59*time.Minute || remaining > time.Hour {
+				t.Errorf("expected the token expiry to be reported, got %v", *expiry)
+			}
+		}
+		if actual := requests.Load(); actual != 1 {
+			t.Errorf("expected 1 token request, got %d", actual)
+		}
+	})
+
+	t.Run("Invalid client", func(t *testing.T) {
+		server, _ := newOAuth2TestServer(t, 3600)
+		t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+		client := &oauth2Client{ClientID: "client", ClientSecret: "wrong", TokenURL: server.URL, Username: "client"}
+		if _, _, err := client.Credentials(context.Background()); err == nil {
+			t.Error("expected an error but got none")
+		}
+	})
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// mockProvider records the hosts it is asked about and returns fixed results.
+type mockProvider struct {
+	name     string
+	match    bool
+	username string
+	password string
+	found    bool
+	err      error
+	calls    *[]string
+}
+
+func (p *mockProvider) Match(string) bool { return p.match }
+
+func (p *mockProvider) Get(_ context.Context, _ string) (string, string, bool, error) {
+	*p.calls = append(*p.calls, p.name)
+	return p.username, p.password, p.found, p.err
+}
+
+func (p *mockProvider) Describe() string { return p.name }
+
+func TestGetProviderCredentials(t *testing.T) {
+	type output struct {
+		username string
+		password string
+		found    bool
+		err      bool
+		calls    []string
+	}
+
+	tests := []struct {
+		name     string
+		input    []mockProvider
+		expected output
+	}{
+		{
+			name: "First provider to find credentials wins",
+			input: []mockProvider{
+				{name: "a", match: true, username: "u1", password: "p1", found: true},
+				{name: "b", match: true, username: "u2", password: "p2", found: true},
+			},
+			expected: output{username: "u1", password: "p1", found: true, calls: []string{"a"}},
+		},
+		{
+			name: "Providers that do not match are skipped",
+			input: []mockProvider{
+				{name: "a", match: false, username: "u1", password: "p1", found: true},
+				{name: "b", match: true, username: "u2", password: "p2", found: true},
+			},
+			expected: output{username: "u2", password: "p2", found: true, calls: []string{"b"}},
+		},
+		{
+			name: "Providers that find nothing fall through",
+			input: []mockProvider{
+				{name: "a", match: true},
+				{name: "b", match: true},
+				{name: "c", match: true, username: "u3", password: "p3", found: true},
+			},
+			expected: output{username: "u3", password: "p3", found: true, calls: []string{"a", "b", "c"}},
+		},
+		{
+			name: "Errors short-circuit",
+			input: []mockProvider{
+				{name: "a", match: true, err: errors.New("failed")},
+				{name: "b", match: true, username: "u2", password: "p2", found: true},
+			},
+			expected: output{err: true, calls: []string{"a"}},
+		},
+		{
+			name: "Nothing found",
+			input: []mockProvider{
+				{name: "a", match: true},
+				{name: "b", match: false},
+			},
+			expected: output{calls: []string{"a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			providers := make([]Provider, len(tt.input))
+			for i := range tt.input {
+				tt.input[i].calls = &calls
+				providers[i] = &tt.input[i]
+			}
+
+			actualUsername, actualPassword, actualFound, actualErr := getProviderCredentials(context.Background(), providers, "example.com")
+			if (actualErr != nil) != tt.expected.err {
+				t.Fatalf("getProviderCredentials() unexpected error state: %v", actualErr)
+			}
+			if actualUsername != tt.expected.username || actualPassword != tt.expected.password || actualFound != tt.expected.found {
+				t.Errorf("getProviderCredentials() actual = (%v, %v, %v), expected (%v, %v, %v)", actualUsername, actualPassword, actualFound, tt.expected.username, tt.expected.password, tt.expected.found)
+			}
+			if !slices.Equal(calls, tt.expected.calls) {
+				t.Errorf("getProviderCredentials() calls actual = (%v), expected (%v)", calls, tt.expected.calls)
+			}
+		})
+	}
+}
+
+func TestGetProviders(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []Provider
+		errContains string
+	}{
+		{
+			name:     "Default order",
+			input:    "",
+			expected: []Provider{providerRegistry["config"], providerRegistry["plugins"], providerRegistry["dockerhub"], providerRegistry["env"]},
+		},
+		{
+			name:     "Configured order",
+			input:    "github, env,ecr",
+			expected: []Provider{providerRegistry["github"], providerRegistry["env"], providerRegistry["ecr"]},
+		},
+		{
+			name:     "Configured plugins",
+			input:    "env,plugins",
+			expected: []Provider{providerRegistry["env"], providerRegistry["plugins"]},
+		},
+		{
+			name:        "Unknown provider",
+			input:       "env,harbor",
+			errContains: `unknown provider "harbor"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DOCKER_CREDENTIAL_ENV_PROVIDERS", tt.input)
+
+			actual, err := getProviders()
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("Expected error to contain %q, but got %v", tt.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getProviders() unexpected error: %v", err)
+			}
+			if len(actual) < len(tt.expected) || !slices.Equal(actual[:len(tt.expected)], tt.expected) {
+				t.Errorf("getProviders() actual = (%v), expected to start with (%v)", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEnvGet_Providers(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "t1")
+	t.Setenv("DOCKER_ghcr_io_USR", "u1")
+	t.Setenv("DOCKER_ghcr_io_PSW", "p1")
+
+	e := Env{}
+
+	t.Setenv("DOCKER_CREDENTIAL_ENV_PROVIDERS", "env,github")
+	if username, password, err := e.Get("https://ghcr.io"); err != nil || username != "u1" || password != "p1" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (u1, p1, <nil>)", username, password, err)
+	}
+
+	t.Setenv("DOCKER_CREDENTIAL_ENV_PROVIDERS", "github,env")
+	if username, password, err := e.Get("https://ghcr.io"); err != nil || username != "x-access-token" || password != "t1" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (x-access-token, t1, <nil>)", username, password, err)
+	}
+
+	t.Setenv("DOCKER_CREDENTIAL_ENV_PROVIDERS", "ecr")
+	if username, password, err := e.Get("https://ghcr.io"); err != nil || username != "" || password != "" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (, , <nil>)", username, password, err)
+	}
+
+	t.Setenv("DOCKER_CREDENTIAL_ENV_PROVIDERS", "env,unknown")
+	if _, _, err := e.Get("https://ghcr.io"); err == nil {
+		t.Error("expected an error but got none")
+	}
+}
@@ -0,0 +1,259 @@
+// Package main provides HashiCorp Vault credential provider implementations.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	vaultEngineKV2 = "kv2"
+	vaultEngineRaw = "raw"
+)
+
+// vaultUnavailableError represents a failure to log in to Vault or to read a secret from it, as opposed to a
+// configuration error.
+type vaultUnavailableError struct {
+	Err error
+}
+
+func (e *vaultUnavailableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *vaultUnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// vaultContext reads registry credentials from HashiCorp Vault.
+// It is enabled by setting DOCKER_CREDENTIAL_ENV_VAULT_PATH to a path template
+// such as "secret/registries/{hostname}", and authenticates with, in order:
+// 1. VAULT_TOKEN
+// 2. AppRole: DOCKER_CREDENTIAL_ENV_VAULT_ROLE_ID and DOCKER_CREDENTIAL_ENV_VAULT_SECRET_ID
+// 3. JWT: DOCKER_CREDENTIAL_ENV_VAULT_JWT_ROLE with DOCKER_CREDENTIAL_ENV_VAULT_JWT or DOCKER_CREDENTIAL_ENV_VAULT_JWT_FILE.
+type vaultContext struct {
+	Addr         string
+	Namespace    string
+	PathTemplate string
+	Engine       string
+
+	httpClient *http.Client
+}
+
+// newVaultContext returns a vaultContext configured from the environment, or nil if Vault is not enabled.
+func newVaultContext() *vaultContext {
+	pathTemplate := os.Getenv(envVaultPath)
+	addr := os.Getenv(envVaultAddr)
+	if pathTemplate == "" || addr == "" {
+		return nil
+	}
+
+	engine := os.Getenv(envVaultEngine)
+	if engine == "" {
+		engine = vaultEngineKV2
+	}
+
+	return &vaultContext{
+		Addr:         strings.TrimSuffix(addr, "/"),
+		Namespace:    os.Getenv(envVaultNamespace),
+		PathTemplate: pathTemplate,
+		Engine:       engine,
+		httpClient:   http.DefaultClient,
+	}
+}
+
+// getVaultCredentials reads the "username" and "password" fields of the Vault secret for the hostname.
+// A missing secret is not an error, and login and read failures are returned as a *vaultUnavailableError.
+// Returns the username, password, a boolean indicating if credentials were found, and any Vault error.
+func getVaultCredentials(ctx context.Context, provider *vaultContext, hostname string) (username, password string, found bool, err error) {
+	if provider == nil {
+		return "", "", false, errors.New("vault: provider must not be nil")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+
+	secretPath, err := provider.secretPath(hostname)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	token, err := provider.token(ctx)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	var response struct {
+		Data map[string]any `json:"data"`
+	}
+	status, err := provider.do(ctx, http.MethodGet, secretPath, token, nil, &response)
+	if status == http.StatusNotFound {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, &vaultUnavailableError{fmt.Errorf("vault: failed to read %q: %w", secretPath, err)}
+	}
+
+	data := response.Data
+	if provider.Engine == vaultEngineKV2 {
+		data, _ = data["data"].(map[string]any)
+	}
+	username, _ = data["username"].(string)
+	password, _ = data["password"].(string)
+	if username == "" || password == "" {
+		return "", "", false, fmt.Errorf("vault: secret %q must contain username and password fields", secretPath)
+	}
+
+	if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+		_, _ = fmt.Fprintf(os.Stderr, "Authenticating access to %q with Vault secret %q\n", hostname, secretPath)
+	}
+
+	return username, password, true, nil
+}
+
+// secretPath expands the path template for the hostname and returns the Vault API path.
+// For KV v2, "data/" is inserted after the mount, so "secret/registries/{hostname}"
+// is read from "secret/data/registries/<hostname>".
+func (p *vaultContext) secretPath(hostname string) (string, error) {
+	path := strings.Trim(strings.ReplaceAll(p.PathTemplate, "{hostname}", hostname), "/")
+
+	switch p.Engine {
+	case vaultEngineKV2:
+		mount, rest, ok := strings.Cut(path, "/")
+		if !ok {
+			return "", fmt.Errorf("vault: path %q must include a mount and a secret path", path)
+		}
+		return mount + "/data/" + rest, nil
+	case vaultEngineRaw:
+		return path, nil
+	default:
+		return "", fmt.Errorf("vault: unsupported engine %q", p.Engine)
+	}
+}
+
+// token returns VAULT_TOKEN, or logs in with AppRole or JWT auth.
+func (p *vaultContext) token(ctx context.Context) (string, error) {
+	if token := os.Getenv(envVaultToken); token != "" {
+		return token, nil
+	}
+
+	var (
+		mount string
+		body  map[string]string
+	)
+	switch {
+	case os.Getenv(envVaultRoleID) != "":
+		mount = "approle"
+		body = map[string]string{
+			"role_id":   os.Getenv(envVaultRoleID),
+			"secret_id": os.Getenv(envVaultSecretID),
+		}
+	case os.Getenv(envVaultJWTRole) != "":
+		jwt := os.Getenv(envVaultJWT)
+		if jwtFile := os.Getenv(envVaultJWTFile); jwt == "" && jwtFile != "" {
+			data, err := os.ReadFile(jwtFile) // #nosec G304 -- path is supplied by the operator
+			if err != nil {
+				return "", fmt.Errorf("vault: failed to read JWT: %w", err)
+			}
+			jwt = strings.TrimSpace(string(data))
+		}
+		mount = "jwt"
+		body = map[string]string{
+			"role": os.Getenv(envVaultJWTRole),
+			"jwt":  jwt,
+		}
+	default:
+		return "", fmt.Errorf("vault: one of %s, %s or %s must be set", envVaultToken, envVaultRoleID, envVaultJWTRole)
+	}
+	if authMount := os.Getenv(envVaultAuthMount); authMount != "" {
+		mount = strings.Trim(authMount, "/")
+	}
+
+	var response struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if _, err := p.do(ctx, http.MethodPost, "auth/"+mount+"/login", "", body, &response); err != nil {
+		return "", &vaultUnavailableError{fmt.Errorf("vault: %s login failed: %w", mount, err)}
+	}
+	if response.Auth.ClientToken == "" {
+		return "", fmt.Errorf("vault: %s login did not return a token", mount)
+	}
+	return response.Auth.ClientToken, nil
+}
+
+// do performs a Vault API request and decodes the JSON response into out.
+// Returns the HTTP status code alongside any error.
+func (p *vaultContext) do(ctx context.Context, method, path, token string, in, out any) (int, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.Addr+"/v1/"+path, body)
+	if err != nil {
+		return 0, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if p.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.Namespace)
+	}
+
+	client := p.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, json.Unmarshal(data, out)
+}
+
+// vaultProvider provides credentials from HashiCorp Vault, if configured.
+type vaultProvider struct{}
+
+// Match implements Provider.
+func (*vaultProvider) Match(string) bool { return newVaultContext() != nil }
+
+// Get implements Provider.
+// If Vault cannot be reached, or the secret cannot be read, no credentials are found, so that the remaining providers
+// still serve registries that are not in Vault.
+func (*vaultProvider) Get(ctx context.Context, host string) (username, password string, found bool, err error) {
+	username, password, found, err = getVaultCredentials(ctx, newVaultContext(), host)
+	if unavailableErr := (*vaultUnavailableError)(nil); errors.As(err, &unavailableErr) {
+		if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: skipping Vault for %q: %v\n", host, unavailableErr)
+		}
+		return "", "", false, nil
+	}
+	return username, password, found, err
+}
+
+// Describe implements Provider.
+func (*vaultProvider) Describe() string { return "HashiCorp Vault" }
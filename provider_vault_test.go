@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newVaultTestServer returns a Vault stand-in serving a KV v2 secret for repo.example.com,
+// a raw secret for dynamic.example.com, and AppRole and JWT logins.
+func newVaultTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/secret/data/registries/repo.example.com", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"data":     map[string]string{"username": "u1", "password": "p1"},
+				"metadata": map[string]any{"version": 1},
+			},
+		})
+	})
+	mux.HandleFunc("GET /v1/registry/creds/dynamic.example.com", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"lease_duration": 3600,
+			"data":           map[string]string{"username": "u2", "password": "p2"},
+		})
+	})
+	mux.HandleFunc("GET /v1/secret/data/registries/incomplete.example.com", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{"data": map[string]string{"username": "u3"}},
+		})
+	})
+	mux.HandleFunc("POST /v1/auth/approle/login", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["role_id"] != "role" || body["secret_id"] != "secret" {
+			http.Error(w, `{"errors":["invalid role or secret ID"]}`, http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"auth": map[string]string{"client_token": "s.token"}})
+	})
+	mux.HandleFunc("POST /v1/auth/gitlab/login", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["role"] != "ci" || body["jwt"] != "id-token" {
+			http.Error(w, `{"errors":["invalid jwt"]}`, http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"auth": map[string]string{"client_token": "s.token"}})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetVaultCredentials(t *testing.T) {
+	type output struct {
+		username string
+		password string
+		found    bool
+		err      bool
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		inputEnv map[string]string
+		expected output
+	}{
+		{
+			name:  "KV v2 with token",
+			input: "repo.example.com",
+			inputEnv: map[string]string{
+				"VAULT_TOKEN": "s.token",
+			},
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name:  "KV v2 with AppRole",
+			input: "repo.example.com",
+			inputEnv: map[string]string{
+				"DOCKER_CREDENTIAL_ENV_VAULT_ROLE_ID":   "role",
+				"DOCKER_CREDENTIAL_ENV_VAULT_SECRET_ID": "secret",
+			},
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name:  "KV v2 with JWT on custom mount",
+			input: "repo.example.com",
+			inputEnv: map[string]string{
+				"DOCKER_CREDENTIAL_ENV_VAULT_JWT_ROLE":   "ci",
+				"DOCKER_CREDENTIAL_ENV_VAULT_JWT":        "id-token",
+				"DOCKER_CREDENTIAL_ENV_VAULT_AUTH_MOUNT": "gitlab",
+			},
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name:  "Raw dynamic secret",
+			input: "dynamic.example.com",
+			inputEnv: map[string]string{
+				"VAULT_TOKEN":                        "s.token",
+				"DOCKER_CREDENTIAL_ENV_VAULT_PATH":   "registry/creds/{hostname}",
+				"DOCKER_CREDENTIAL_ENV_VAULT_ENGINE": "raw",
+			},
+			expected: output{username: "u2", password: "p2", found: true},
+		},
+		{
+			name:  "Missing secret",
+			input: "other.example.com",
+			inputEnv: map[string]string{
+				"VAULT_TOKEN": "s.token",
+			},
+			expected: output{found: false},
+		},
+		{
+			name:  "Incomplete secret",
+			input: "incomplete.example.com",
+			inputEnv: map[string]string{
+				"VAULT_TOKEN": "s.token",
+			},
+			expected: output{err: true},
+		},
+		{
+			name:  "Permission denied",
+			input: "repo.example.com",
+			inputEnv: map[string]string{
+				"VAULT_TOKEN": "s.other",
+			},
+			expected: output{err: true},
+		},
+		{
+			name:     "No authentication",
+			input:    "repo.example.com",
+			inputEnv: map[string]string{},
+			expected: output{err: true},
+		},
+	}
+
+	server := newVaultTestServer(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("VAULT_ADDR", server.URL)
+			t.Setenv("VAULT_TOKEN", "")
+			t.Setenv("DOCKER_CREDENTIAL_ENV_VAULT_PATH", "secret/registries/{hostname}")
+			for k, v := range tt.inputEnv {
+				t.Setenv(k, v)
+			}
+
+			actualUsername, actualPassword, actualFound, actualErr := getVaultCredentials(context.Background(), newVaultContext(), tt.input)
+			if (actualErr != nil) != tt.expected.err {
+				t.Fatalf("getVaultCredentials(%v) unexpected error state: %v", tt.input, actualErr)
+			}
+			if actualUsername != tt.expected.username || actualPassword != tt.expected.password || actualFound != tt.expected.found {
+				t.Errorf("getVaultCredentials(%v) actual = (%v, %v, %v), expected (%v, %v, %v)", tt.input, actualUsername, actualPassword, actualFound, tt.expected.username, tt.expected.password, tt.expected.found)
+			}
+		})
+	}
+}
+
+func TestEnvGet_Vault(t *testing.T) {
+	server := newVaultTestServer(t)
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "s.token")
+	t.Setenv("DOCKER_CREDENTIAL_ENV_VAULT_PATH", "secret/registries/{hostname}")
+
+	e := Env{}
+
+	if username, password, err := e.Get("https://repo.example.com"); err != nil || username != "u1" || password != "p1" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (u1, p1, <nil>)", username, password, err)
+	}
+
+	// Environment variables take precedence over Vault
+	t.Setenv("DOCKER_example_com_USR", "u0")
+	t.Setenv("DOCKER_example_com_PSW", "p0")
+	if username, password, err := e.Get("https://repo.example.com"); err != nil || username != "u0" || password != "p0" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (u0, p0, <nil>)", username, password, err)
+	}
+}
+
+func TestEnvGet_VaultUnavailable(t *testing.T) {
+	server := newVaultTestServer(t)
+	t.Setenv("VAULT_TOKEN", "s.other")
+	t.Setenv("DOCKER_CREDENTIAL_ENV_VAULT_PATH", "secret/registries/{hostname}")
+	t.Setenv("DOCKER_repo_example_com_USR", "u0")
+	t.Setenv("DOCKER_repo_example_com_PSW", "p0")
+	t.Setenv("DOCKER_CREDENTIAL_ENV_PROVIDERS", "vault,env")
+
+	e := Env{}
+
+	for name, addr := range map[string]string{
+		"Permission denied": server.URL,
+		"Unreachable":       "http://127.0.0.1:1",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("VAULT_ADDR", addr)
+			if username, password, err := e.Get("https://repo.example.com"); err != nil || username != "u0" || password != "p0" {
+				t.Errorf("Get() actual = (%v, %v, %v), expected (u0, p0, <nil>)", username, password, err)
+			}
+		})
+	}
+
+	// Configuration errors are still reported
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("DOCKER_CREDENTIAL_ENV_VAULT_ENGINE", "kv3")
+	if _, _, err := e.Get("https://repo.example.com"); err == nil {
+		t.Error("expected an error but got none")
+	}
+}
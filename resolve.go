@@ -0,0 +1,422 @@
+// Package main provides the resolve command, which explains how credentials are selected for a registry.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// redactedSecret replaces secrets in the output of the resolve command.
+const redactedSecret = "<redacted>"
+
+// Results of consulting a provider, as recorded in a resolveTrace.
+const (
+	providerSkipped  = "skipped"
+	providerNotFound = "not found"
+	providerFound    = "found"
+	providerFailed   = "failed"
+)
+
+// resolveValidationTimeout bounds the time spent validating credentials against the registry.
+const resolveValidationTimeout = 30 * time.Second
+
+// resolveHTTPClient is the HTTP client used to validate credentials.
+var resolveHTTPClient = http.DefaultClient
+
+// dockerHubRegistryHostname is the hostname of the Docker Hub registry API.
+const dockerHubRegistryHostname = "registry-1.docker.io"
+
+// resolveProviderTrace records the result of consulting a provider.
+type resolveProviderTrace struct {
+	Provider string `json:"provider"`
+	Result   string `json:"result"`
+	Error    string `json:"error,omitempty"`
+}
+
+// resolveTrace records how credentials were resolved, for the resolve command.
+type resolveTrace struct {
+	Providers      []resolveProviderTrace
+	AWSCredentials string
+	AWSProfile     string
+	AWSRoleARN     string
+}
+
+// traceKey is the context key for the resolveTrace of the credentials being resolved.
+type traceKey struct{}
+
+// withTrace returns a context in which the resolution of credentials is recorded, and the trace it is recorded in.
+func withTrace(ctx context.Context) (context.Context, *resolveTrace) {
+	trace := &resolveTrace{}
+	return context.WithValue(ctx, traceKey{}, trace), trace
+}
+
+// traceFromContext returns the trace carried by the context, or nil if resolution is not being traced.
+// All resolveTrace methods may be called on a nil trace.
+func traceFromContext(ctx context.Context) *resolveTrace {
+	trace, _ := ctx.Value(traceKey{}).(*resolveTrace)
+	return trace
+}
+
+// addProvider records the result of consulting a provider.
+func (t *resolveTrace) addProvider(provider Provider, result string, err error) {
+	if t == nil {
+		return
+	}
+	entry := resolveProviderTrace{Provider: provider.Describe(), Result: result}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	t.Providers = append(t.Providers, entry)
+}
+
+// setAWSCredentials records the source of the AWS credentials used for an ECR token exchange, and the profile if any.
+func (t *resolveTrace) setAWSCredentials(source, profile string) {
+	if t == nil {
+		return
+	}
+	t.AWSCredentials, t.AWSProfile = source, profile
+}
+
+// setAWSRoleARN records the role assumed for an ECR token exchange.
+func (t *resolveTrace) setAWSRoleARN(roleARN string) {
+	if t == nil {
+		return
+	}
+	t.AWSRoleARN = roleARN
+}
+
+// resolveVariable is an environment variable that may hold credentials, and whether it is set.
+type resolveVariable struct {
+	Name string `json:"name"`
+	Set  bool   `json:"set"`
+}
+
+// resolveAWS is the AWS configuration used for an ECR token exchange.
+type resolveAWS struct {
+	Credentials string `json:"credentials"`
+	Profile     string `json:"profile,omitempty"`
+	RoleARN     string `json:"roleArn,omitempty"`
+}
+
+// resolveValidation is the result of validating the credentials against the registry.
+type resolveValidation struct {
+	Valid   bool   `json:"valid"`
+	Message string `json:"message"`
+}
+
+// resolveResult is the output of the resolve command. The password is always redacted.
+type resolveResult struct {
+	Registry   string                 `json:"registry"`
+	Hostname   string                 `json:"hostname"`
+	Found      bool                   `json:"found"`
+	Provider   string                 `json:"provider,omitempty"`
+	Username   string                 `json:"username,omitempty"`
+	Password   string                 `json:"password,omitempty"`
+	ExpiresAt  time.Time              `json:"expiresAt,omitzero"`
+	AWS        *resolveAWS            `json:"aws,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Validation *resolveValidation     `json:"validation,omitempty"`
+	Providers  []resolveProviderTrace `json:"providers"`
+	Variables  []resolveVariable      `json:"variables"`
+}
+
+// resolveCmd handles the logic for the "resolve" command.
+type resolveCmd struct {
+	Registry string
+	Output   string
+	Validate bool
+	Out      io.Writer
+}
+
+// Run resolves the credentials for the registry and writes an explanation of the result.
+func (c *resolveCmd) Run() error {
+	hostname, err := getHostname(c.Registry)
+	if err != nil {
+		return fmt.Errorf("invalid registry %q: %w", c.Registry, err)
+	}
+	result := resolveResult{Registry: c.Registry, Hostname: hostname}
+
+	if result.Variables, err = getEnvCandidates(hostname); err != nil {
+		return err
+	}
+
+	ctx, trace := withTrace(context.Background())
+	ctx, expiry := withExpiry(ctx)
+	username, password, found, resolveErr := resolveServerCredentials(ctx, c.Registry)
+
+	result.Providers = trace.Providers
+	if trace.AWSCredentials != "" {
+		result.AWS = &resolveAWS{Credentials: trace.AWSCredentials, Profile: trace.AWSProfile, RoleARN: trace.AWSRoleARN}
+	}
+	if resolveErr != nil {
+		result.Error = resolveErr.Error()
+	} else if found {
+		result.Found = true
+		result.Provider = trace.Providers[len(trace.Providers)-1].Provider
+		result.Username = username
+		result.Password = redactedSecret
+		result.ExpiresAt = expiry.UTC()
+	}
+
+	if c.Validate && found {
+		ctx, cancel := context.WithTimeout(context.Background(), resolveValidationTimeout)
+		defer cancel()
+		result.Validation = &resolveValidation{Valid: true}
+		if result.Validation.Message, err = validateRegistryCredentials(ctx, c.Registry, username, password); err != nil {
+			result.Validation = &resolveValidation{Valid: false, Message: err.Error()}
+		}
+	}
+
+	if err := c.write(&result); err != nil {
+		return err
+	}
+
+	switch {
+	case resolveErr != nil:
+		return fmt.Errorf("failed to resolve credentials for %q: %w", c.Registry, resolveErr)
+	case result.Validation != nil && !result.Validation.Valid:
+		return fmt.Errorf("credentials for %q are invalid", c.Registry)
+	}
+	return nil
+}
+
+// write writes the result in the requested output format.
+func (c *resolveCmd) write(result *resolveResult) error {
+	if c.Output == "json" {
+		encoder := json.NewEncoder(c.Out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+
+	w := tabwriter.NewWriter(c.Out, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Registry:\t%s\n", result.Registry)
+	_, _ = fmt.Fprintf(w, "Hostname:\t%s\n", result.Hostname)
+	switch {
+	case result.Error != "":
+		_, _ = fmt.Fprintf(w, "Error:\t%s\n", result.Error)
+	case !result.Found:
+		_, _ = fmt.Fprintf(w, "Provider:\t%s\n", "none (no credentials found)")
+	default:
+		_, _ = fmt.Fprintf(w, "Provider:\t%s\n", result.Provider)
+		_, _ = fmt.Fprintf(w, "Username:\t%s\n", result.Username)
+		_, _ = fmt.Fprintf(w, "Password:\t%s\n", result.Password)
+		if !result.ExpiresAt.IsZero() {
+			_, _ = fmt.Fprintf(w, "Expires:\t%s\n", result.ExpiresAt.Format(time.RFC3339))
+		}
+	}
+	if result.AWS != nil {
+		_, _ = fmt.Fprintf(w, "AWS credentials:\t%s\n", result.AWS.Credentials)
+		if result.AWS.Profile != "" {
+			_, _ = fmt.Fprintf(w, "AWS profile:\t%s\n", result.AWS.Profile)
+		}
+		if result.AWS.RoleARN != "" {
+			_, _ = fmt.Fprintf(w, "AWS role:\t%s\n", result.AWS.RoleARN)
+		}
+	}
+	if result.Validation != nil {
+		status := "valid"
+		if !result.Validation.Valid {
+			status = "invalid"
+		}
+		_, _ = fmt.Fprintf(w, "Validation:\t%s: %s\n", status, result.Validation.Message)
+	}
+
+	_, _ = fmt.Fprintf(w, "\nPROVIDER\tRESULT\n")
+	for _, provider := range result.Providers {
+		if provider.Error != "" {
+			_, _ = fmt.Fprintf(w, "%s\t%s: %s\n", provider.Provider, provider.Result, provider.Error)
+		} else {
+			_, _ = fmt.Fprintf(w, "%s\t%s\n", provider.Provider, provider.Result)
+		}
+	}
+
+	_, _ = fmt.Fprintf(w, "\nVARIABLE\tSET\n")
+	for _, variable := range result.Variables {
+		set := "no"
+		if variable.Set {
+			set = "yes"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", variable.Name, set)
+	}
+	return w.Flush()
+}
+
+// getEnvCandidates returns the DOCKER_<hostname>_* variables consulted for the hostname, in the order
+// they are tried: for each number of DNS labels removed from the left and each enabled encoding, the
+// username and password pairs, the token, the token file and the OAuth2 client credentials.
+func getEnvCandidates(hostname string) (variables []resolveVariable, err error) {
+	scheme, err := getEnvScheme()
+	if err != nil {
+		return nil, err
+	}
+
+	labels := strings.Split(hostname, ".")
+	var names []string
+	for offset := 0; offset <= len(labels); offset++ {
+		for _, encoding := range getEnvEncodings() {
+			scheme.Encoding = encoding
+			envCredentials, envToken, envTokenFile := getEnvVariables(scheme, labels, offset)
+			for _, envCredential := range envCredentials {
+				names = append(names, envCredential.Username, envCredential.Password)
+			}
+			names = append(names, envToken, envTokenFile)
+			for _, suffix := range []string{envClientIDSuffix, envClientSecretSuffix, envTokenURLSuffix} {
+				names = append(names, getEnvVariable(scheme, labels, offset, suffix))
+			}
+		}
+	}
+
+	for _, name := range names {
+		if slices.ContainsFunc(variables, func(v resolveVariable) bool { return v.Name == name }) {
+			continue
+		}
+		_, set := lookupEnvVariable(name)
+		variables = append(variables, resolveVariable{Name: name, Set: set})
+	}
+	return variables, nil
+}
+
+// authParam matches the parameters of a WWW-Authenticate challenge.
+var authParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// validateRegistryCredentials checks the credentials against the /v2/ endpoint of the registry, following
+// a bearer token challenge if it is offered. Identity tokens are exchanged as OAuth2 refresh tokens.
+// Returns a description of the outcome, and an error if the credentials were rejected.
+func validateRegistryCredentials(ctx context.Context, registry, username, password string) (string, error) {
+	server, err := url.Parse(defaultScheme + strings.TrimPrefix(registry, defaultScheme))
+	if err != nil {
+		return "", err
+	}
+	host := server.Host
+	if isDockerHub(server.Hostname()) {
+		host = dockerHubRegistryHostname
+	}
+	endpoint := defaultScheme + host + "/v2/"
+
+	resp, err := registryRequest(ctx, http.MethodGet, endpoint, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return "registry allows anonymous access", nil
+	case http.StatusUnauthorized:
+	default:
+		return "", fmt.Errorf("unexpected status %s from %s", resp.Status, endpoint)
+	}
+
+	challenge, params, _ := strings.Cut(resp.Header.Get("WWW-Authenticate"), " ")
+	switch strings.ToLower(challenge) {
+	case "basic":
+		resp, err = registryRequest(ctx, http.MethodGet, endpoint, nil, func(req *http.Request) {
+			req.SetBasicAuth(username, password)
+		})
+	case "bearer":
+		challengeParams := map[string]string{}
+		for _, match := range authParam.FindAllStringSubmatch(params, -1) {
+			challengeParams[strings.ToLower(match[1])] = match[2]
+		}
+		realm := challengeParams["realm"]
+		if realm == "" {
+			return "", fmt.Errorf("bearer challenge from %s has no realm", endpoint)
+		}
+		if username == identityTokenUsername {
+			form := url.Values{
+				"grant_type":    {"refresh_token"},
+				"refresh_token": {password},
+				"service":       {challengeParams["service"]},
+				"client_id":     {"docker-credential-env"},
+			}
+			resp, err = registryRequest(ctx, http.MethodPost, realm, strings.NewReader(form.Encode()), func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			})
+		} else {
+			query := url.Values{"account": {username}}
+			if service := challengeParams["service"]; service != "" {
+				query.Set("service", service)
+			}
+			resp, err = registryRequest(ctx, http.MethodGet, realm+"?"+query.Encode(), nil, func(req *http.Request) {
+				req.SetBasicAuth(username, password)
+			})
+		}
+	default:
+		return "", fmt.Errorf("unsupported authentication challenge %q from %s", challenge, endpoint)
+	}
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("credentials rejected by %s: %s", resp.Request.URL.Host, resp.Status)
+	}
+	return "credentials accepted by " + resp.Request.URL.Host, nil
+}
+
+// registryRequest performs a request, discarding the response body.
+func registryRequest(ctx context.Context, method, endpoint string, body io.Reader, prepare func(*http.Request)) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if prepare != nil {
+		prepare(req)
+	}
+	resp, err := resolveHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	_ = resp.Body.Close()
+	return resp, nil
+}
+
+// RunResolveCommand is the main entry point for the resolve command.
+func RunResolveCommand(args []string, out io.Writer) error {
+	const usage = "Usage: docker-credential-env resolve [--output table|json] [--validate] <registry>"
+
+	cmd := &resolveCmd{
+		Output: "table",
+		Out:    out,
+	}
+
+	var registries []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--output" || arg == "-o":
+			if i+1 == len(args) {
+				return fmt.Errorf("%q requires a format\n%s", arg, usage)
+			}
+			i++
+			cmd.Output = args[i]
+		case strings.HasPrefix(arg, "--output="):
+			cmd.Output = strings.TrimPrefix(arg, "--output=")
+		case arg == "--validate":
+			cmd.Validate = true
+		case strings.HasPrefix(arg, "-"):
+			return fmt.Errorf("unknown flag %q\n%s", arg, usage)
+		default:
+			registries = append(registries, arg)
+		}
+	}
+
+	switch {
+	case len(registries) == 0:
+		return errors.New("missing argument\n" + usage)
+	case len(registries) > 1:
+		return errors.New("too many arguments\n" + usage)
+	case cmd.Output != "table" && cmd.Output != "json":
+		return fmt.Errorf("invalid output format %q\n%s", cmd.Output, usage)
+	}
+	cmd.Registry = registries[0]
+
+	return cmd.Run()
+}
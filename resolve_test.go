@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunResolveCommand_Errors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		args        []string
+		errContains string
+	}{
+		{"no args", []string{}, "missing argument"},
+		{"too many args", []string{"ghcr.io", "docker.io"}, "too many arguments"},
+		{"missing format", []string{"ghcr.io", "--output"}, `"--output" requires a format`},
+		{"invalid format", []string{"--output=yaml", "ghcr.io"}, `invalid output format "yaml"`},
+		{"unknown flag", []string{"--verbose", "ghcr.io"}, `unknown flag "--verbose"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := RunResolveCommand(tc.args, new(bytes.Buffer))
+			if err == nil {
+				t.Fatalf("Expected an error but got none")
+			}
+			if !strings.Contains(err.Error(), tc.errContains) {
+				t.Errorf("Expected error to contain %q, but got %q", tc.errContains, err.Error())
+			}
+		})
+	}
+}
+
+func TestRunResolveCommand(t *testing.T) {
+	t.Setenv("DOCKER_CREDENTIAL_ENV_PROVIDERS", "config,env,github")
+	t.Setenv("DOCKER_CREDENTIAL_ENV_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("DOCKER_example_com_USR", "u1")
+	t.Setenv("DOCKER_example_com_PSW", "s3cret")
+	t.Setenv("DOCKER_broken_example_net_TOKEN_FILE", "/nonexistent/token")
+
+	t.Run("Table output", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		if err := RunResolveCommand([]string{"https://registry.example.com/v2/"}, out); err != nil {
+			t.Fatalf("RunResolveCommand() unexpected error: %v", err)
+		}
+		output := out.String()
+		for _, expected := range []string{
+			"Hostname:  registry.example.com",
+			"Provider:  DOCKER_<hostname>_* environment variables",
+			"Username:  u1",
+			"Password:  <redacted>",
+			"credential rules file",
+			"not found",
+			"DOCKER_registry_example_com_USR",
+			"DOCKER_example_com_PSW",
+		} {
+			if !strings.Contains(output, expected) {
+				t.Errorf("RunResolveCommand() output does not contain %q:\n%s", expected, output)
+			}
+		}
+		if strings.Contains(output, "s3cret") {
+			t.Errorf("RunResolveCommand() output contains the password:\n%s", output)
+		}
+	})
+
+	t.Run("JSON output", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		if err := RunResolveCommand([]string{"--output", "json", "registry.example.com"}, out); err != nil {
+			t.Fatalf("RunResolveCommand() unexpected error: %v", err)
+		}
+		if strings.Contains(out.String(), "s3cret") {
+			t.Errorf("RunResolveCommand() output contains the password:\n%s", out.String())
+		}
+
+		var actual resolveResult
+		if err := json.Unmarshal(out.Bytes(), &actual); err != nil {
+			t.Fatalf("RunResolveCommand() invalid output %q: %v", out.String(), err)
+		}
+		if !actual.Found || actual.Username != "u1" || actual.Password != redactedSecret {
+			t.Errorf("RunResolveCommand() actual = (%v, %v, %v), expected (true, u1, %v)", actual.Found, actual.Username, actual.Password, redactedSecret)
+		}
+		var providers []string
+		for _, provider := range actual.Providers {
+			providers = append(providers, provider.Provider+": "+provider.Result)
+		}
+		expectedProviders := "credential rules file: not found, DOCKER_<hostname>_* environment variables: found"
+		if strings.Join(providers, ", ") != expectedProviders {
+			t.Errorf("RunResolveCommand() providers actual = (%v), expected (%v)", strings.Join(providers, ", "), expectedProviders)
+		}
+		if len(actual.Variables) < 2 || actual.Variables[0] != (resolveVariable{Name: "DOCKER_registry_example_com_USR"}) {
+			t.Errorf("RunResolveCommand() variables actual = (%v), expected to start with DOCKER_registry_example_com_USR", actual.Variables)
+		}
+		for _, variable := range actual.Variables {
+			if expected := variable.Name == "DOCKER_example_com_USR" || variable.Name == "DOCKER_example_com_PSW"; variable.Set != expected {
+				t.Errorf("RunResolveCommand() variable %v set actual = (%v), expected (%v)", variable.Name, variable.Set, expected)
+			}
+		}
+	})
+
+	t.Run("No credentials", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		if err := RunResolveCommand([]string{"-o", "json", "registry.example.org"}, out); err != nil {
+			t.Fatalf("RunResolveCommand() unexpected error: %v", err)
+		}
+		var actual resolveResult
+		if err := json.Unmarshal(out.Bytes(), &actual); err != nil {
+			t.Fatalf("RunResolveCommand() invalid output %q: %v", out.String(), err)
+		}
+		if actual.Found || actual.Provider != "" || len(actual.Providers) != 3 {
+			t.Errorf("RunResolveCommand() actual = (%v, %v, %v), expected (false, , 3 providers)", actual.Found, actual.Provider, len(actual.Providers))
+		}
+	})
+
+	t.Run("Provider error", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		err := RunResolveCommand([]string{"broken.example.net"}, out)
+		if err == nil || !strings.Contains(err.Error(), "failed to resolve credentials") {
+			t.Fatalf("Expected error to contain %q, but got %v", "failed to resolve credentials", err)
+		}
+		if !strings.Contains(out.String(), "Error:") || !strings.Contains(out.String(), "failed:") {
+			t.Errorf("RunResolveCommand() output does not report the error:\n%s", out.String())
+		}
+	})
+}
+
+func TestRunResolveCommand_Validate(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry.test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/token":
+			username, password, ok := r.BasicAuth()
+			if !ok || username != "u1" || password != "p1" || r.URL.Query().Get("service") != "registry.test" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"token":"t"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	resolveHTTPClient = server.Client()
+	t.Cleanup(func() { resolveHTTPClient = http.DefaultClient })
+
+	registry := strings.TrimPrefix(server.URL, "https://")
+	t.Setenv("DOCKER_CREDENTIAL_ENV_PROVIDERS", "env")
+	t.Setenv("DOCKER_127_0_0_1_USR", "u1")
+
+	tests := []struct {
+		name     string
+		password string
+		expected resolveValidation
+	}{
+		{
+			name:     "Valid credentials",
+			password: "p1",
+			expected: resolveValidation{Valid: true, Message: "credentials accepted by " + registry},
+		},
+		{
+			name:     "Invalid credentials",
+			password: "wrong",
+			expected: resolveValidation{Valid: false, Message: "credentials rejected by " + registry + ": 401 Unauthorized"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DOCKER_127_0_0_1_PSW", tt.password)
+
+			out := new(bytes.Buffer)
+			err := RunResolveCommand([]string{"--validate", "--output=json", registry}, out)
+			if (err != nil) == tt.expected.Valid {
+				t.Fatalf("RunResolveCommand() unexpected error state: %v", err)
+			}
+
+			var actual resolveResult
+			if err := json.Unmarshal(out.Bytes(), &actual); err != nil {
+				t.Fatalf("RunResolveCommand() invalid output %q: %v", out.String(), err)
+			}
+			if actual.Validation == nil || *actual.Validation != tt.expected {
+				t.Errorf("RunResolveCommand() validation actual = (%+v), expected (%+v)", actual.Validation, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Package main provides the configurable environment variable naming scheme.
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envSchemeName matches the characters permitted in a configured prefix or suffix.
+var envSchemeName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// envCredentialNames is a pair of username and password environment variable names, or suffixes thereof.
+type envCredentialNames struct {
+	Username string
+	Password string
+}
+
+// envScheme describes how environment variable names are constructed: <Prefix>_<encoded hostname>_<suffix>.
+type envScheme struct {
+	Prefix      string
+	Credentials []envCredentialNames
+	Encoding    envEncoding
+}
+
+// defaultEnvScheme matches the variables created by the Jenkins credentials() binding.
+var defaultEnvScheme = envScheme{
+	Prefix:      envPrefix,
+	Credentials: []envCredentialNames{{Username: envUsernameSuffix, Password: envPasswordSuffix}},
+	Encoding:    legacyEncoding,
+}
+
+// getEnvScheme returns the naming scheme configured by DOCKER_CREDENTIAL_ENV_PREFIX and DOCKER_CREDENTIAL_ENV_SUFFIXES.
+// The suffixes are a comma-separated list of <username>:<password> pairs, tried in order, e.g. "USERNAME:PASSWORD,USR:PSW".
+func getEnvScheme() (envScheme, error) {
+	scheme := defaultEnvScheme
+
+	if prefix := os.Getenv(envSchemePrefix); prefix != "" {
+		if !envSchemeName.MatchString(prefix) {
+			return scheme, fmt.Errorf("invalid %s %q: must contain only letters, digits and underscores", envSchemePrefix, prefix)
+		}
+		scheme.Prefix = prefix
+	}
+
+	if suffixes := os.Getenv(envSchemeSuffixes); suffixes != "" {
+		scheme.Credentials = nil
+		for _, pair := range strings.Split(suffixes, ",") {
+			username, password, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok || !envSchemeName.MatchString(username) || !envSchemeName.MatchString(password) {
+				return scheme, fmt.Errorf("invalid %s entry %q: expected <username suffix>:<password suffix>", envSchemeSuffixes, pair)
+			}
+			scheme.Credentials = append(scheme.Credentials, envCredentialNames{Username: username, Password: password})
+		}
+	}
+
+	return scheme, nil
+}
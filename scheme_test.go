@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetEnvScheme(t *testing.T) {
+	type output struct {
+		prefix      string
+		credentials []envCredentialNames
+		err         bool
+	}
+
+	tests := []struct {
+		name     string
+		inputEnv map[string]string
+		expected output
+	}{
+		{
+			name:     "Default",
+			expected: output{prefix: "DOCKER", credentials: []envCredentialNames{{Username: "USR", Password: "PSW"}}},
+		},
+		{
+			name:     "Prefix",
+			inputEnv: map[string]string{"DOCKER_CREDENTIAL_ENV_PREFIX": "TENANT_A"},
+			expected: output{prefix: "TENANT_A", credentials: []envCredentialNames{{Username: "USR", Password: "PSW"}}},
+		},
+		{
+			name:     "Suffixes",
+			inputEnv: map[string]string{"DOCKER_CREDENTIAL_ENV_SUFFIXES": "USERNAME:PASSWORD, USR:PSW"},
+			expected: output{prefix: "DOCKER", credentials: []envCredentialNames{{Username: "USERNAME", Password: "PASSWORD"}, {Username: "USR", Password: "PSW"}}},
+		},
+		{
+			name:     "Invalid prefix",
+			inputEnv: map[string]string{"DOCKER_CREDENTIAL_ENV_PREFIX": "TENANT-A"},
+			expected: output{err: true},
+		},
+		{
+			name:     "Missing password suffix",
+			inputEnv: map[string]string{"DOCKER_CREDENTIAL_ENV_SUFFIXES": "USERNAME"},
+			expected: output{err: true},
+		},
+		{
+			name:     "Empty suffix",
+			inputEnv: map[string]string{"DOCKER_CREDENTIAL_ENV_SUFFIXES": "USERNAME:PASSWORD,"},
+			expected: output{err: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.inputEnv {
+				t.Setenv(k, v)
+			}
+
+			actual, err := getEnvScheme()
+			if (err != nil) != tt.expected.err {
+				t.Fatalf("getEnvScheme() unexpected error state: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if actual.Prefix != tt.expected.prefix || len(actual.Credentials) != len(tt.expected.credentials) {
+				t.Fatalf("getEnvScheme() actual = (%v, %v), expected (%v, %v)", actual.Prefix, actual.Credentials, tt.expected.prefix, tt.expected.credentials)
+			}
+			for i := range actual.Credentials {
+				if actual.Credentials[i] != tt.expected.credentials[i] {
+					t.Errorf("getEnvScheme() actual = (%v, %v), expected (%v, %v)", actual.Prefix, actual.Credentials, tt.expected.prefix, tt.expected.credentials)
+				}
+			}
+		})
+	}
+}
+
+func TestGetEnvCredentials_Scheme(t *testing.T) {
+	type output struct {
+		username string
+		password string
+		found    bool
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected output
+	}{
+		{
+			name:     "First suffix pair",
+			input:    "repo.example.com",
+			expected: output{username: "u1", password: "p1", found: true},
+		},
+		{
+			name:     "Second suffix pair",
+			input:    "other.example.com",
+			expected: output{username: "u2", password: "p2", found: true},
+		},
+		{
+			name:     "Incomplete pair is skipped",
+			input:    "mixed.example.com",
+			expected: output{username: "u3", password: "p3", found: true},
+		},
+		{
+			name:     "Default prefix is ignored",
+			input:    "default.example.com",
+			expected: output{found: false},
+		},
+	}
+
+	t.Setenv("DOCKER_CREDENTIAL_ENV_PREFIX", "TENANT_A")
+	t.Setenv("DOCKER_CREDENTIAL_ENV_SUFFIXES", "USERNAME:PASSWORD,USR:PSW")
+	t.Setenv("TENANT_A_repo_example_com_USERNAME", "u1")
+	t.Setenv("TENANT_A_repo_example_com_PASSWORD", "p1")
+	t.Setenv("TENANT_A_repo_example_com_USR", "u0")
+	t.Setenv("TENANT_A_repo_example_com_PSW", "p0")
+	t.Setenv("TENANT_A_other_example_com_USR", "u2")
+	t.Setenv("TENANT_A_other_example_com_PSW", "p2")
+	t.Setenv("TENANT_A_mixed_example_com_USERNAME", "u0")
+	t.Setenv("TENANT_A_mixed_example_com_USR", "u3")
+	t.Setenv("TENANT_A_mixed_example_com_PSW", "p3")
+	t.Setenv("DOCKER_default_example_com_USR", "u4")
+	t.Setenv("DOCKER_default_example_com_PSW", "p4")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actualUsername, actualPassword, actualFound, actualErr := getEnvCredentials(context.Background(), tt.input)
+			if actualErr != nil {
+				t.Errorf("getEnvCredentials(%v) unexpected error: %v", tt.input, actualErr)
+			}
+			if actualUsername != tt.expected.username || actualPassword != tt.expected.password || actualFound != tt.expected.found {
+				t.Errorf("getEnvCredentials(%v) actual = (%v, %v, %v), expected (%v, %v, %v)", tt.input, actualUsername, actualPassword, actualFound, tt.expected.username, tt.expected.password, tt.expected.found)
+			}
+		})
+	}
+
+	t.Run("Invalid scheme", func(t *testing.T) {
+		t.Setenv("DOCKER_CREDENTIAL_ENV_SUFFIXES", "USERNAME")
+		if _, _, _, err := getEnvCredentials(context.Background(), "repo.example.com"); err == nil {
+			t.Error("expected an error but got none")
+		}
+	})
+}
@@ -0,0 +1,199 @@
+// Package main provides resolution of indirect secret references: env:, file: and exec: values.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	secretRefEnv  = "env:"
+	secretRefFile = "file:"
+	secretRefExec = "exec:"
+)
+
+const (
+	// maxSecretRefDepth bounds chains of env: references, which would otherwise loop forever on cycles.
+	maxSecretRefDepth = 8
+	// defaultSecretRefExecTimeout bounds exec: references unless DOCKER_CREDENTIAL_ENV_EXEC_TIMEOUT is set.
+	defaultSecretRefExecTimeout = 10 * time.Second
+	// maxSecretRefSize bounds the output of file: and exec: references.
+	maxSecretRefSize = 64 << 10
+)
+
+// secretRefsEnabled reports whether indirect secret references have been enabled with DOCKER_CREDENTIAL_ENV_SECRET_REFS.
+// They are opt-in, so that literal secrets starting with a reference prefix keep working.
+func secretRefsEnabled() bool {
+	b, err := strconv.ParseBool(os.Getenv(envSecretRefs))
+	return err == nil && b
+}
+
+// resolveCredentials resolves references in both the username and password.
+func resolveCredentials(ctx context.Context, username, password string) (string, string, error) {
+	username, err := resolveSecretRef(ctx, username)
+	if err != nil {
+		return "", "", err
+	}
+	password, err = resolveSecretRef(ctx, password)
+	if err != nil {
+		return "", "", err
+	}
+	return username, password, nil
+}
+
+// resolveSecretRef resolves an indirect secret reference, if references are enabled:
+// - env:NAME returns the value of the environment variable NAME, itself resolved
+// - file:/path returns the content of the file, with trailing newlines removed
+// - exec:command args... returns the stdout of the command, with trailing newlines removed
+//
+// Any other value is returned unchanged.
+func resolveSecretRef(ctx context.Context, value string) (string, error) {
+	if !secretRefsEnabled() {
+		return value, nil
+	}
+
+	for depth := 0; depth < maxSecretRefDepth; depth++ {
+		switch {
+		case strings.HasPrefix(value, secretRefEnv):
+			name := strings.TrimPrefix(value, secretRefEnv)
+			resolved, found := os.LookupEnv(name)
+			if !found {
+				return "", fmt.Errorf("secret reference: environment variable %s not found", name)
+			}
+			value = resolved
+		case strings.HasPrefix(value, secretRefFile):
+			return readSecretFile(strings.TrimPrefix(value, secretRefFile))
+		case strings.HasPrefix(value, secretRefExec):
+			return execSecretCommand(ctx, strings.TrimPrefix(value, secretRefExec))
+		default:
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("secret reference: exceeded maximum depth of %d", maxSecretRefDepth)
+}
+
+// readSecretFile reads a file: reference.
+func readSecretFile(path string) (string, error) {
+	file, err := os.Open(path) // #nosec G304 -- path is supplied by the operator
+	if err != nil {
+		return "", fmt.Errorf("secret reference: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(&limitedReader{r: file, n: maxSecretRefSize}); err != nil {
+		return "", fmt.Errorf("secret reference: %s: %w", path, err)
+	}
+	return strings.TrimRight(buf.String(), "\r\n"), nil
+}
+
+// execSecretCommand runs an exec: reference. The command line is split on whitespace,
+// honouring single and double quotes; no shell is involved.
+func execSecretCommand(ctx context.Context, commandLine string) (string, error) {
+	args, err := splitCommandLine(commandLine)
+	if err != nil {
+		return "", fmt.Errorf("secret reference: %w", err)
+	}
+	if len(args) == 0 {
+		return "", errors.New("secret reference: empty exec command")
+	}
+
+	timeout := defaultSecretRefExecTimeout
+	if value := os.Getenv(envSecretRefExecTimeout); value != "" {
+		if timeout, err = time.ParseDuration(value); err != nil || timeout <= 0 {
+			return "", fmt.Errorf("secret reference: invalid %s %q", envSecretRefExecTimeout, value)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) // #nosec G204 -- command is supplied by the operator
+	cmd.WaitDelay = commandWaitDelay
+	cmd.Stdout = &limitedWriter{w: &stdout, n: maxSecretRefSize}
+	// Not os.Stderr itself, which background processes of the command could otherwise hold open after a timeout
+	cmd.Stderr = struct{ io.Writer }{os.Stderr}
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("secret reference: %q timed out after %s", args[0], timeout)
+		}
+		return "", fmt.Errorf("secret reference: %q failed: %w", args[0], err)
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
+
+// splitCommandLine splits a command line into arguments, honouring single and double quotes.
+func splitCommandLine(s string) (args []string, err error) {
+	var (
+		current strings.Builder
+		quote   rune
+		inArg   bool
+	)
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// errSecretRefTooLarge is returned when a file: or exec: reference exceeds maxSecretRefSize.
+var errSecretRefTooLarge = fmt.Errorf("exceeds %d bytes", maxSecretRefSize)
+
+// limitedReader is an io.Reader that fails, rather than truncating, once more than n bytes are read.
+type limitedReader struct {
+	r io.Reader
+	n int
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if l.n -= n; l.n < 0 {
+		return n, errSecretRefTooLarge
+	}
+	return n, err
+}
+
+// limitedWriter is an io.Writer that fails once more than n bytes are written.
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.n -= len(p); l.n < 0 {
+		return 0, errSecretRefTooLarge
+	}
+	return l.w.Write(p)
+}
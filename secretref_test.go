@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveSecretRef(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	writeFile(t, secretFile, "s3cret\n")
+
+	tests := []struct {
+		name        string
+		input       string
+		inputEnv    map[string]string
+		expected    string
+		errContains string
+		posixOnly   bool
+	}{
+		{
+			name:     "Disabled",
+			input:    "env:CI_SECRET",
+			inputEnv: map[string]string{"DOCKER_CREDENTIAL_ENV_SECRET_REFS": "false", "CI_SECRET": "s3cret"},
+			expected: "env:CI_SECRET",
+		},
+		{
+			name:     "Literal",
+			input:    "literal",
+			expected: "literal",
+		},
+		{
+			name:     "Environment reference",
+			input:    "env:CI_SECRET",
+			inputEnv: map[string]string{"CI_SECRET": "s3cret"},
+			expected: "s3cret",
+		},
+		{
+			name:     "Chained reference",
+			input:    "env:CI_SECRET_REF",
+			inputEnv: map[string]string{"CI_SECRET_REF": "file:" + secretFile},
+			expected: "s3cret",
+		},
+		{
+			name:        "Missing environment variable",
+			input:       "env:CI_MISSING",
+			errContains: "CI_MISSING not found",
+		},
+		{
+			name:        "Reference cycle",
+			input:       "env:CI_A",
+			inputEnv:    map[string]string{"CI_A": "env:CI_B", "CI_B": "env:CI_A"},
+			errContains: "exceeded maximum depth",
+		},
+		{
+			name:     "File reference",
+			input:    "file:" + secretFile,
+			expected: "s3cret",
+		},
+		{
+			name:        "Missing file",
+			input:       "file:" + filepath.Join(t.TempDir(), "missing"),
+			errContains: "no such file",
+		},
+		{
+			name:      "Exec reference with quotes",
+			input:     `exec:sh -c 'echo "s3cret"'`,
+			expected:  "s3cret",
+			posixOnly: true,
+		},
+		{
+			name:        "Exec failure",
+			input:       "exec:sh -c 'exit 1'",
+			errContains: "failed",
+			posixOnly:   true,
+		},
+		{
+			name:        "Exec timeout",
+			input:       "exec:sleep 5",
+			inputEnv:    map[string]string{"DOCKER_CREDENTIAL_ENV_EXEC_TIMEOUT": "100ms"},
+			errContains: "timed out after 100ms",
+			posixOnly:   true,
+		},
+		{
+			name:        "Unterminated quote",
+			input:       "exec:op read 'op://vault/item",
+			errContains: "unterminated ' quote",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.posixOnly && runtime.GOOS == "windows" {
+				t.Skip("requires a POSIX shell")
+			}
+			t.Setenv("DOCKER_CREDENTIAL_ENV_SECRET_REFS", "true")
+			for k, v := range tt.inputEnv {
+				t.Setenv(k, v)
+			}
+
+			actual, err := resolveSecretRef(context.Background(), tt.input)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("Expected error to contain %q, but got %v", tt.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveSecretRef(%v) unexpected error: %v", tt.input, err)
+			}
+			if actual != tt.expected {
+				t.Errorf("resolveSecretRef(%v) actual = (%v), expected (%v)", tt.input, actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveSecretRef_TimeoutWithBackgroundProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Setenv("DOCKER_CREDENTIAL_ENV_SECRET_REFS", "true")
+	t.Setenv("DOCKER_CREDENTIAL_ENV_EXEC_TIMEOUT", "200ms")
+
+	// The shell's child keeps stdout open after the shell is killed
+	start := time.Now()
+	_, err := resolveSecretRef(context.Background(), `exec:sh -c "sleep 8; echo p"`)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 200ms") {
+		t.Errorf("Expected error to contain %q, but got %v", "timed out after 200ms", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond+commandWaitDelay+time.Second {
+		t.Errorf("resolveSecretRef() returned after %s, expected close to the 200ms timeout", elapsed)
+	}
+}
+
+func TestEnvGet_SecretRefs(t *testing.T) {
+	t.Setenv("DOCKER_CREDENTIAL_ENV_SECRET_REFS", "true")
+	t.Setenv("CI_REGISTRY_SECRET", "p1")
+	t.Setenv("CI_GITHUB_SECRET", "t1")
+	t.Setenv("DOCKER_example_com_USR", "u1")
+	t.Setenv("DOCKER_example_com_PSW", "env:CI_REGISTRY_SECRET")
+	t.Setenv("GITHUB_TOKEN", "env:CI_GITHUB_SECRET")
+
+	e := Env{}
+
+	if username, password, err := e.Get("https://repo.example.com"); err != nil || username != "u1" || password != "p1" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (u1, p1, <nil>)", username, password, err)
+	}
+	if username, password, err := e.Get("https://ghcr.io"); err != nil || username != "x-access-token" || password != "t1" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (x-access-token, t1, <nil>)", username, password, err)
+	}
+
+	t.Setenv("DOCKER_example_com_PSW", "env:CI_MISSING")
+	if _, _, err := e.Get("https://repo.example.com"); err == nil {
+		t.Error("expected an error but got none")
+	}
+}
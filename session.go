@@ -0,0 +1,248 @@
+// Package main provides the session store, which keeps credentials from `docker login` for the rest of a session.
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	credhelpers "github.com/docker/docker-credential-helpers/credentials"
+)
+
+// defaultSessionTTL is the lifetime of a session entry unless DOCKER_CREDENTIAL_ENV_SESSION_TTL is set.
+const defaultSessionTTL = 12 * time.Hour
+
+// sessionFileName matches the entry files of the session store, and temporary files left by an interrupted Add.
+var sessionFileName = regexp.MustCompile(`^(?:[0-9a-f]{64}\.json|\.entry-[0-9]+)$`)
+
+// sessionEntry is a credential stored by `docker login` for the duration of a session.
+type sessionEntry struct {
+	ServerURL string    `json:"serverURL"`
+	Username  string    `json:"username"`
+	Secret    string    `json:"secret"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+// sessionStore keeps credentials from `docker login` in a per-session directory, so that later
+// commands in the same session can use them without writing to ~/.docker/config.json.
+// The store lives in $XDG_RUNTIME_DIR (removed when the user session ends) unless
+// DOCKER_CREDENTIAL_ENV_SESSION_DIR names a job-specific path.
+type sessionStore struct {
+	Dir string
+	TTL time.Duration
+}
+
+// newSessionStore returns the session store, or nil if it is not enabled.
+// It is enabled by DOCKER_CREDENTIAL_ENV_SESSION=true or by setting DOCKER_CREDENTIAL_ENV_SESSION_DIR.
+func newSessionStore() (*sessionStore, error) {
+	dir := os.Getenv(envSessionDir)
+	if enabled, err := strconv.ParseBool(os.Getenv(envSession)); dir == "" && (err != nil || !enabled) {
+		return nil, nil
+	}
+
+	if dir == "" {
+		runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+		if runtimeDir == "" {
+			return nil, fmt.Errorf("session: XDG_RUNTIME_DIR is not set; set %s to a session-specific directory", envSessionDir)
+		}
+		dir = filepath.Join(runtimeDir, "docker-credential-env")
+	}
+
+	ttl := defaultSessionTTL
+	if value := os.Getenv(envSessionTTL); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("session: invalid %s %q", envSessionTTL, value)
+		}
+		ttl = parsed
+	}
+
+	return &sessionStore{Dir: dir, TTL: ttl}, nil
+}
+
+// Add stores credentials for the server URL, replacing any existing entry.
+func (s *sessionStore) Add(creds *credhelpers.Credentials) error {
+	if creds == nil {
+		return errors.New("session: credentials must not be nil")
+	}
+	path, err := s.entryPath(creds.ServerURL)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return fmt.Errorf("session: failed to create %q: %w", s.Dir, err)
+	}
+
+	data, err := json.Marshal(&sessionEntry{
+		ServerURL: creds.ServerURL,
+		Username:  creds.Username,
+		Secret:    creds.Secret,
+		Expiry:    time.Now().Add(s.TTL),
+	})
+	if err != nil {
+		return fmt.Errorf("session: %w", err)
+	}
+
+	// Write via a temporary file so that a concurrent Get never sees a partial entry
+	tmp, err := os.CreateTemp(s.Dir, ".entry-*")
+	if err != nil {
+		return fmt.Errorf("session: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("session: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("session: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves unexpired credentials for the server URL. Expired entries are removed.
+// Returns the username, password, a boolean indicating if credentials were found, and any read error.
+func (s *sessionStore) Get(serverURL string) (username, password string, found bool, err error) {
+	path, err := s.entryPath(serverURL)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- path is derived from a hash
+	if errors.Is(err, os.ErrNotExist) {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, fmt.Errorf("session: %w", err)
+	}
+
+	var entry sessionEntry
+	if err := json.Unmarshal(data, &entry); err != nil || time.Now().After(entry.Expiry) {
+		_ = s.remove(path)
+		return "", "", false, nil
+	}
+
+	if b, err := strconv.ParseBool(os.Getenv(envDebugMode)); err == nil && b {
+		expiration := entry.Expiry.UTC().Format(time.RFC3339)
+		_, _ = fmt.Fprintf(os.Stderr, "Authenticating access to %q with session credentials (expire at %s UTC)\n", serverURL, expiration)
+	}
+
+	return entry.Username, entry.Secret, true, nil
+}
+
+// Delete removes the entry for the server URL. Deleting a missing entry is not an error.
+func (s *sessionStore) Delete(serverURL string) error {
+	path, err := s.entryPath(serverURL)
+	if err != nil {
+		return err
+	}
+	if err := s.remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("session: %w", err)
+	}
+	return nil
+}
+
+// remove deletes an entry, and the store directory once it is empty.
+func (s *sessionStore) remove(path string) error {
+	err := os.Remove(path)
+	if entries, readErr := os.ReadDir(s.Dir); readErr == nil && len(entries) == 0 {
+		_ = os.Remove(s.Dir)
+	}
+	return err
+}
+
+// Clear removes all entries, including unexpired ones, and the store directory once it is empty.
+// Files not created by the store are kept. Returns the number of entries removed.
+func (s *sessionStore) Clear() (int, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("session: %w", err)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !sessionFileName.MatchString(entry.Name()) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.Dir, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, fmt.Errorf("session: %w", err)
+		}
+		if filepath.Ext(entry.Name()) == ".json" {
+			removed++
+		}
+	}
+	if entries, err := os.ReadDir(s.Dir); err == nil && len(entries) == 0 {
+		if err := os.Remove(s.Dir); err != nil {
+			return removed, fmt.Errorf("session: %w", err)
+		}
+	}
+	return removed, nil
+}
+
+// entryPath returns the file holding the entry for the server URL.
+// Entries are keyed by hostname, so that all forms of a registry URL share one entry.
+func (s *sessionStore) entryPath(serverURL string) (string, error) {
+	hostname, err := getHostname(serverURL)
+	if err != nil {
+		return "", fmt.Errorf("session: %w", err)
+	}
+	key := sha256.Sum256([]byte(hostname))
+	return filepath.Join(s.Dir, hex.EncodeToString(key[:])+".json"), nil
+}
+
+// sessionProvider provides credentials from the session store, if enabled.
+type sessionProvider struct{}
+
+// Match implements Provider.
+func (*sessionProvider) Match(string) bool { return true }
+
+// Get implements Provider.
+func (*sessionProvider) Get(ctx context.Context, host string) (username, password string, found bool, err error) {
+	session, err := newSessionStore()
+	if err != nil || session == nil {
+		return "", "", false, err
+	}
+	return session.Get(serverURLFromContext(ctx, host))
+}
+
+// Describe implements Provider.
+func (*sessionProvider) Describe() string { return "session store" }
+
+// RunSessionCommand is the main entry point for the session command.
+func RunSessionCommand(args []string, out io.Writer) error {
+	const usage = "Usage: docker-credential-env session clear"
+
+	if len(args) != 1 || args[0] != "clear" {
+		return errors.New("expected \"clear\"\n" + usage)
+	}
+
+	session, err := newSessionStore()
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return fmt.Errorf("session store is not enabled; set %s=true or %s", envSession, envSessionDir)
+	}
+
+	removed, err := session.Clear()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "Removed %d session entries from %q\n", removed, session.Dir)
+	return err
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	credhelpers "github.com/docker/docker-credential-helpers/credentials"
+)
+
+func TestNewSessionStore(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputEnv map[string]string
+		expected string
+		err      bool
+	}{
+		{
+			name:     "Disabled",
+			inputEnv: map[string]string{},
+		},
+		{
+			name: "Runtime directory",
+			inputEnv: map[string]string{
+				"DOCKER_CREDENTIAL_ENV_SESSION": "true",
+				"XDG_RUNTIME_DIR":               "/run/user/1000",
+			},
+			expected: "/run/user/1000/docker-credential-env",
+		},
+		{
+			name: "Job-specific directory",
+			inputEnv: map[string]string{
+				"DOCKER_CREDENTIAL_ENV_SESSION_DIR": "/builds/tmp/session",
+				"XDG_RUNTIME_DIR":                   "/run/user/1000",
+			},
+			expected: "/builds/tmp/session",
+		},
+		{
+			name: "No runtime directory",
+			inputEnv: map[string]string{
+				"DOCKER_CREDENTIAL_ENV_SESSION": "true",
+			},
+			err: true,
+		},
+		{
+			name: "Invalid TTL",
+			inputEnv: map[string]string{
+				"DOCKER_CREDENTIAL_ENV_SESSION_DIR": "/builds/tmp/session",
+				"DOCKER_CREDENTIAL_ENV_SESSION_TTL": "forever",
+			},
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_RUNTIME_DIR", "")
+			t.Setenv("DOCKER_CREDENTIAL_ENV_SESSION", "")
+			t.Setenv("DOCKER_CREDENTIAL_ENV_SESSION_DIR", "")
+			for k, v := range tt.inputEnv {
+				t.Setenv(k, v)
+			}
+
+			session, err := newSessionStore()
+			if (err != nil) != tt.err {
+				t.Fatalf("newSessionStore() unexpected error state: %v", err)
+			}
+			if (session == nil && tt.expected != "") || (session != nil && session.Dir != tt.expected) {
+				t.Errorf("newSessionStore() actual = %v, expected %q", session, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSessionStore(t *testing.T) {
+	session := &sessionStore{Dir: filepath.Join(t.TempDir(), "session"), TTL: time.Hour}
+
+	creds := &credhelpers.Credentials{ServerURL: "https://index.docker.io/v1/", Username: "u1", Secret: "p1"}
+	if err := session.Add(creds); err != nil {
+		t.Fatalf("Add() unexpected error: %v", err)
+	}
+
+	// All Docker Hub aliases share one entry
+	username, password, found, err := session.Get("docker.io")
+	if username != "u1" || password != "p1" || !found || err != nil {
+		t.Errorf("Get() actual = (%v, %v, %v, %v), expected (u1, p1, true, <nil>)", username, password, found, err)
+	}
+
+	if err := session.Delete("https://index.docker.io/v1/"); err != nil {
+		t.Fatalf("Delete() unexpected error: %v", err)
+	}
+	if _, _, found, err := session.Get("docker.io"); found || err != nil {
+		t.Errorf("Get() after Delete() actual = (%v, %v), expected (false, <nil>)", found, err)
+	}
+	if _, err := os.Stat(session.Dir); !os.IsNotExist(err) {
+		t.Errorf("expected empty session directory to be removed, got %v", err)
+	}
+
+	// Deleting again is idempotent
+	if err := session.Delete("https://index.docker.io/v1/"); err != nil {
+		t.Errorf("Delete() unexpected error: %v", err)
+	}
+}
+
+func TestSessionStore_Expiry(t *testing.T) {
+	session := &sessionStore{Dir: t.TempDir(), TTL: -time.Second}
+
+	if err := session.Add(&credhelpers.Credentials{ServerURL: "repo.example.com", Username: "u1", Secret: "p1"}); err != nil {
+		t.Fatalf("Add() unexpected error: %v", err)
+	}
+	if _, _, found, err := session.Get("repo.example.com"); found || err != nil {
+		t.Errorf("Get() actual = (%v, %v), expected (false, <nil>)", found, err)
+	}
+	if entries, _ := os.ReadDir(session.Dir); len(entries) != 0 {
+		t.Errorf("expected expired entry to be removed, got %v", entries)
+	}
+}
+
+func TestRunSessionCommand_Clear(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "session")
+	t.Setenv("DOCKER_CREDENTIAL_ENV_SESSION_DIR", dir)
+
+	session, err := newSessionStore()
+	if err != nil {
+		t.Fatalf("newSessionStore() unexpected error: %v", err)
+	}
+	for _, serverURL := range []string{"repo.example.com", "ghcr.io"} {
+		if err := session.Add(&credhelpers.Credentials{ServerURL: serverURL, Username: "u1", Secret: "p1"}); err != nil {
+			t.Fatalf("Add() unexpected error: %v", err)
+		}
+	}
+	writeFile(t, filepath.Join(dir, ".entry-123"), "{")
+
+	out := new(bytes.Buffer)
+	if err := RunSessionCommand([]string{"clear"}, out); err != nil {
+		t.Fatalf("RunSessionCommand() unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Removed 2 session entries") {
+		t.Errorf("RunSessionCommand() output actual = (%q), expected to contain (%q)", out.String(), "Removed 2 session entries")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected session directory to be removed, got %v", err)
+	}
+
+	// Clearing again is idempotent, and other files are kept
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "notes.txt"), "keep")
+	if err := RunSessionCommand([]string{"clear"}, new(bytes.Buffer)); err != nil {
+		t.Fatalf("RunSessionCommand() unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("expected unrelated file to be kept, got %v", err)
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		if err := RunSessionCommand(nil, new(bytes.Buffer)); err == nil || !strings.Contains(err.Error(), "Usage") {
+			t.Errorf("Expected error to contain %q, but got %v", "Usage", err)
+		}
+		t.Setenv("DOCKER_CREDENTIAL_ENV_SESSION_DIR", "")
+		t.Setenv("DOCKER_CREDENTIAL_ENV_SESSION", "")
+		if err := RunSessionCommand([]string{"clear"}, new(bytes.Buffer)); err == nil || !strings.Contains(err.Error(), "not enabled") {
+			t.Errorf("Expected error to contain %q, but got %v", "not enabled", err)
+		}
+	})
+}
+
+func TestEnvSession(t *testing.T) {
+	t.Setenv("DOCKER_CREDENTIAL_ENV_SESSION_DIR", t.TempDir())
+	t.Setenv("DOCKER_example_com_USR", "u0")
+	t.Setenv("DOCKER_example_com_PSW", "p0")
+
+	e := Env{}
+
+	for _, serverURL := range []string{"https://repo.example.com", "https://repo.example.net"} {
+		if err := e.Add(&credhelpers.Credentials{ServerURL: serverURL, Username: "u1", Secret: "p1"}); err != nil {
+			t.Fatalf("Add() unexpected error: %v", err)
+		}
+	}
+
+	// Environment variables take precedence over the session store
+	if username, password, err := e.Get("https://repo.example.com"); err != nil || username != "u0" || password != "p0" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (u0, p0, <nil>)", username, password, err)
+	}
+	if username, password, err := e.Get("https://repo.example.net"); err != nil || username != "u1" || password != "p1" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (u1, p1, <nil>)", username, password, err)
+	}
+
+	if err := e.Delete("https://repo.example.net"); err != nil {
+		t.Fatalf("Delete() unexpected error: %v", err)
+	}
+	if username, password, err := e.Get("https://repo.example.net"); err != nil || username != "" || password != "" {
+		t.Errorf("Get() actual = (%v, %v, %v), expected (, , <nil>)", username, password, err)
+	}
+}
@@ -9,16 +9,26 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/docker/cli/cli/config/configfile"
 	"github.com/goccy/go-yaml"
 )
 
+const (
+	// setupBackupTimeFormat is the UTC timestamp in the names of config file backups, which sort chronologically.
+	setupBackupTimeFormat = "20060102T150405.000000000Z"
+	// setupBackupLimit is the number of config file backups kept.
+	setupBackupLimit = 10
+)
+
 // setupCmd handles the logic for the "setup" command.
 type setupCmd struct {
 	Command    string
 	Out        io.Writer
 	Registry   string
+	Registries []string
+	Force      bool
 	configPath string
 }
 
@@ -29,6 +39,12 @@ func (c *setupCmd) Run() error {
 		return c.show()
 	case "default":
 		return c.configure(true)
+	case "unset-default":
+		return c.unsetDefault()
+	case "remove":
+		return c.remove()
+	case "restore":
+		return c.restore()
 	default:
 		return c.configure(false)
 	}
@@ -81,11 +97,13 @@ func (c *setupCmd) configure(defaultSetup bool) error {
 		}
 	}
 
-	if err := c.ensureDockerDir(); err != nil {
+	unlock, err := c.lock()
+	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	config, err := c.loadConfig()
+	data, config, err := c.loadDocument()
 	if err != nil {
 		return err
 	}
@@ -103,16 +121,16 @@ func (c *setupCmd) configure(defaultSetup bool) error {
 
 	// Configure credential helper
 	if defaultSetup {
-		config.CredentialsStore = "env"
+		data, err = setJSONMember(data, nil, "credsStore", "env")
 	} else {
-		if config.CredentialHelpers == nil {
-			config.CredentialHelpers = make(map[string]string)
-		}
-		config.CredentialHelpers[c.Registry] = "env"
+		data, err = setJSONMember(data, []string{"credHelpers"}, c.Registry, "env")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update Docker config file %q: %w", c.configPath, err)
 	}
 
 	// Save configuration
-	if err = c.saveConfig(config); err != nil {
+	if err = c.saveConfig(data); err != nil {
 		return err
 	}
 
@@ -124,12 +142,141 @@ func (c *setupCmd) configure(defaultSetup bool) error {
 	return err
 }
 
+// unsetDefault removes the default credential store, if it is "env" or --force is given.
+func (c *setupCmd) unsetDefault() error {
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, config, err := c.loadDocument()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case config.CredentialsStore == "":
+		_, err = fmt.Fprintln(c.Out, "Default credential store is not configured")
+		return err
+	case config.CredentialsStore != "env" && !c.Force:
+		_, err = fmt.Fprintf(c.Out, "Default credential store is configured to use %q credential helper, not \"env\"; use --force to unset it\n", config.CredentialsStore)
+		return err
+	}
+
+	if data, err = deleteJSONMember(data, nil, "credsStore", false); err != nil {
+		return fmt.Errorf("failed to update Docker config file %q: %w", c.configPath, err)
+	}
+	if err = c.saveConfig(data); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(c.Out, "Default credential store %q successfully unset\n", config.CredentialsStore)
+	return err
+}
+
+// remove removes the credential helpers of the registries, if they are "env" or --force is given.
+func (c *setupCmd) remove() error {
+	for _, registry := range c.Registries {
+		if err := validateRegistry(registry); err != nil {
+			return err
+		}
+	}
+
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, config, err := c.loadDocument()
+	if err != nil {
+		return err
+	}
+
+	var removed []string
+	for _, registry := range c.Registries {
+		helper, ok := config.CredentialHelpers[registry]
+		switch {
+		case !ok:
+			_, err = fmt.Fprintf(c.Out, "Registry %q is not configured\n", registry)
+		case helper != "env" && !c.Force:
+			_, err = fmt.Fprintf(c.Out, "Registry %q is configured to use %q credential helper, not \"env\"; use --force to remove it\n", registry, helper)
+		default:
+			if data, err = deleteJSONMember(data, []string{"credHelpers"}, registry, true); err != nil {
+				return fmt.Errorf("failed to update Docker config file %q: %w", c.configPath, err)
+			}
+			removed = append(removed, registry)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	if err = c.saveConfig(data); err != nil {
+		return err
+	}
+
+	for _, registry := range removed {
+		if _, err = fmt.Fprintf(c.Out, "Registry %q successfully removed\n", registry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restore replaces the Docker config file with its latest backup, which is then deleted, so that repeated restores
+// step back through the backups.
+func (c *setupCmd) restore() error {
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	backups, err := c.listBackups()
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		return fmt.Errorf("no backup of Docker config file %q found", c.configPath)
+	}
+	backupPath := backups[len(backups)-1]
+
+	backupData, err := os.ReadFile(backupPath)
+	if err != nil {
+		return fmt.Errorf("failed to read backup %q: %w", backupPath, err)
+	}
+	var config configfile.ConfigFile
+	if err := json.Unmarshal(backupData, &config); err != nil {
+		return fmt.Errorf("failed to parse backup %q: %w", backupPath, err)
+	}
+
+	if err = c.writeConfig(backupData); err != nil {
+		return err
+	}
+	if err = os.Remove(backupPath); err != nil {
+		return fmt.Errorf("failed to remove backup %q: %w", backupPath, err)
+	}
+
+	_, err = fmt.Fprintf(c.Out, "Docker config file %q successfully restored from backup %q\n", c.configPath, backupPath)
+	return err
+}
+
 func (c *setupCmd) validateRegistry() error {
-	if c.Registry == "" {
+	return validateRegistry(c.Registry)
+}
+
+// validateRegistry checks that a registry is a bare hostname, optionally with a port.
+func validateRegistry(registry string) error {
+	if registry == "" {
 		return errors.New("registry cannot be empty")
 	}
-	if strings.ContainsAny(c.Registry, " /\\") {
-		return fmt.Errorf("invalid registry: %q", c.Registry)
+	if strings.ContainsAny(registry, " /\\") {
+		return fmt.Errorf("invalid registry: %q", registry)
 	}
 	return nil
 }
@@ -142,36 +289,200 @@ func (c *setupCmd) ensureDockerDir() error {
 	return nil
 }
 
+// targetPath returns the path of the Docker config file, or of the file it links to.
+func (c *setupCmd) targetPath() string {
+	if resolved, err := filepath.EvalSymlinks(c.configPath); err == nil {
+		return resolved
+	}
+	return c.configPath
+}
+
+// lock takes an exclusive advisory lock on the Docker config file, so that concurrent setup commands apply their
+// changes one after another. The lock is held on a separate ".lock" file, as the config file itself is replaced on
+// save, and is released by calling the returned function.
+func (c *setupCmd) lock() (func(), error) {
+	if err := c.ensureDockerDir(); err != nil {
+		return nil, err
+	}
+
+	lockPath := c.targetPath() + ".lock"
+	lockFile, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock Docker config file %q: %w", c.configPath, err)
+	}
+	if err := lockFileExclusive(lockFile); err != nil {
+		_ = lockFile.Close()
+		return nil, fmt.Errorf("failed to lock Docker config file %q: %w", c.configPath, err)
+	}
+
+	return func() {
+		_ = unlockFile(lockFile)
+		_ = lockFile.Close()
+	}, nil
+}
+
 func (c *setupCmd) loadConfig() (*configfile.ConfigFile, error) {
+	_, config, err := c.loadDocument()
+	return config, err
+}
+
+// loadDocument reads the Docker config file for editing, returning its content, or an empty object if it does not exist,
+// and its parsed form.
+func (c *setupCmd) loadDocument() ([]byte, *configfile.ConfigFile, error) {
 	configData, err := os.ReadFile(c.configPath)
 	if os.IsNotExist(err) {
-		return configfile.New(c.configPath), nil
+		return []byte("{}"), configfile.New(c.configPath), nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to read Docker config file %q: %w", c.configPath, err)
+		return nil, nil, fmt.Errorf("failed to read Docker config file %q: %w", c.configPath, err)
 	}
 	var config configfile.ConfigFile
 	if err := json.Unmarshal(configData, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse Docker config file %q: %w", c.configPath, err)
+		return nil, nil, fmt.Errorf("failed to parse Docker config file %q: %w", c.configPath, err)
 	}
-	return &config, nil
+	return configData, &config, nil
 }
 
-func (c *setupCmd) saveConfig(config *configfile.ConfigFile) error {
-	configData, err := json.MarshalIndent(config, "", "\t")
+// saveConfig backs up the Docker config file, if it exists, and replaces it.
+func (c *setupCmd) saveConfig(configData []byte) error {
+	if err := c.backupConfig(); err != nil {
+		return err
+	}
+	return c.writeConfig(configData)
+}
+
+// backupConfig copies the Docker config file, if it exists, to a timestamped ".bak" file alongside it, with the same
+// permissions, and deletes all but the latest backups.
+func (c *setupCmd) backupConfig() error {
+	configPath := c.targetPath()
+	info, err := os.Stat(configPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to back up Docker config file %q: %w", c.configPath, err)
+	}
+	configData, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to back up Docker config file %q: %w", c.configPath, err)
+	}
+
+	// Never overwrite an existing backup, even if the clock has not advanced
+	var backupFile *os.File
+	for now := time.Now().UTC(); ; now = now.Add(time.Nanosecond) {
+		backupPath := configPath + "." + now.Format(setupBackupTimeFormat) + ".bak"
+		backupFile, err = os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+		if !os.IsExist(err) {
+			break
+		}
+	}
 	if err != nil {
-		return fmt.Errorf("failed to marshal Docker config: %w", err)
+		return fmt.Errorf("failed to back up Docker config file %q: %w", c.configPath, err)
 	}
-	if err = os.WriteFile(c.configPath, configData, 0600); err != nil {
+	_, err = backupFile.Write(configData)
+	if closeErr := backupFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(backupFile.Name())
+		return fmt.Errorf("failed to back up Docker config file %q: %w", c.configPath, err)
+	}
+
+	backups, err := c.listBackups()
+	if err != nil {
+		return err
+	}
+	for _, backupPath := range backups[:max(len(backups)-setupBackupLimit, 0)] {
+		if err := os.Remove(backupPath); err != nil {
+			return fmt.Errorf("failed to remove backup %q: %w", backupPath, err)
+		}
+	}
+	return nil
+}
+
+// listBackups returns the paths of the backups of the Docker config file, oldest first.
+func (c *setupCmd) listBackups() ([]string, error) {
+	configPath := c.targetPath()
+	entries, err := os.ReadDir(filepath.Dir(configPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups of Docker config file %q: %w", c.configPath, err)
+	}
+
+	var backups []string
+	prefix := filepath.Base(configPath) + "."
+	for _, entry := range entries {
+		timestamp, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if timestamp, ok = strings.CutSuffix(timestamp, ".bak"); !ok {
+			continue
+		}
+		if _, err := time.Parse(setupBackupTimeFormat, timestamp); err == nil {
+			backups = append(backups, filepath.Join(filepath.Dir(configPath), entry.Name()))
+		}
+	}
+	slices.Sort(backups)
+	return backups, nil
+}
+
+// writeConfig atomically replaces the Docker config file, or the file it links to, through a temporary file in the
+// same directory, keeping its permissions.
+func (c *setupCmd) writeConfig(configData []byte) (err error) {
+	configPath := c.targetPath()
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(configPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(configPath), filepath.Base(configPath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write Docker config file %q: %w", c.configPath, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tempFile.Name())
+		}
+	}()
+
+	_, err = tempFile.Write(configData)
+	if err == nil {
+		err = tempFile.Chmod(mode)
+	}
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), configPath)
+	}
+	if err != nil {
 		return fmt.Errorf("failed to write Docker config file %q: %w", c.configPath, err)
 	}
 	return nil
 }
 
+// getDockerConfigPath returns the path of the Docker client configuration file,
+// in the directory named by DOCKER_CONFIG or in ~/.docker.
+func getDockerConfigPath() (string, error) {
+	if dockerConfigDir := os.Getenv("DOCKER_CONFIG"); dockerConfigDir != "" {
+		return filepath.Join(dockerConfigDir, "config.json"), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".docker", "config.json"), nil
+}
+
 // RunSetupCommand is the main entry point for the setup command.
 func RunSetupCommand(args []string, out io.Writer) error {
+	const usage = "Usage: docker-credential-env setup <show|default|unset-default [--force]|remove [--force] <registry>...|restore|registry-url>"
+
 	if len(args) < 1 {
-		return errors.New("missing argument\nUsage: docker-credential-env setup <show|default|registry-url>")
+		return errors.New("missing argument\n" + usage)
 	}
 
 	cmd := &setupCmd{
@@ -180,22 +491,33 @@ func RunSetupCommand(args []string, out io.Writer) error {
 	}
 
 	// Determine config path
-	if dockerConfigDir := os.Getenv("DOCKER_CONFIG"); dockerConfigDir != "" {
-		cmd.configPath = filepath.Join(dockerConfigDir, "config.json")
-	} else {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("failed to get user home directory: %w", err)
-		}
-		cmd.configPath = filepath.Join(homeDir, ".docker", "config.json")
+	var err error
+	if cmd.configPath, err = getDockerConfigPath(); err != nil {
+		return err
 	}
 
 	// Validate arguments
 	switch cmd.Command {
-	case "show", "default":
+	case "show", "default", "restore":
 		if len(args) > 1 {
 			return fmt.Errorf("%q command does not accept additional arguments", cmd.Command)
 		}
+	case "unset-default", "remove":
+		for _, arg := range args[1:] {
+			switch {
+			case arg == "--force":
+				cmd.Force = true
+			case strings.HasPrefix(arg, "-"):
+				return fmt.Errorf("unknown flag %q\n%s", arg, usage)
+			case cmd.Command == "unset-default":
+				return fmt.Errorf("%q command does not accept additional arguments", cmd.Command)
+			default:
+				cmd.Registries = append(cmd.Registries, arg)
+			}
+		}
+		if cmd.Command == "remove" && len(cmd.Registries) == 0 {
+			return errors.New("missing registry\n" + usage)
+		}
 	default: // Assumes registry
 		cmd.Registry = args[0]
 	}
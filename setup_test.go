@@ -3,9 +3,14 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/docker/cli/cli/config/configfile"
@@ -32,6 +37,11 @@ func TestRunSetupCommand_Errors(t *testing.T) {
 		{"default with extra args", []string{"default", "extra"}, `"default" command does not accept additional arguments`},
 		{"show with extra args", []string{"show", "extra"}, `"show" command does not accept additional arguments`},
 		{"invalid registry", []string{"invalid/registry"}, "invalid registry"},
+		{"unset-default with extra args", []string{"unset-default", "extra"}, `"unset-default" command does not accept additional arguments`},
+		{"unset-default with unknown flag", []string{"unset-default", "--all"}, `unknown flag "--all"`},
+		{"remove without registry", []string{"remove"}, "missing registry"},
+		{"remove with only force", []string{"remove", "--force"}, "missing registry"},
+		{"remove invalid registry", []string{"remove", "docker.io", "invalid/registry"}, "invalid registry"},
 	}
 
 	for _, tc := range testCases {
@@ -148,3 +158,403 @@ func TestRunSetupCommand_Registry(t *testing.T) {
 		t.Errorf("Expected credHelper for 'docker.io' to be 'env', got %q", helper)
 	}
 }
+
+func TestRunSetupCommand_NullCredHelpers(t *testing.T) {
+	tempDir := setupTestEnvironment(t)
+	configPath := filepath.Join(tempDir, "config.json")
+	writeFile(t, configPath, `{"auths": {}, "credHelpers": null}`)
+
+	for _, args := range [][]string{{"remove", "ghcr.io"}, {"ghcr.io"}} {
+		if err := RunSetupCommand(args, new(bytes.Buffer)); err != nil {
+			t.Fatalf("RunSetupCommand(%v) failed: %v", args, err)
+		}
+	}
+
+	config := readTestConfig(t, configPath)
+	if !maps.Equal(config.CredentialHelpers, map[string]string{"ghcr.io": "env"}) {
+		t.Errorf("Expected credHelpers to be %v, got %v", map[string]string{"ghcr.io": "env"}, config.CredentialHelpers)
+	}
+}
+
+// writeTestConfig writes a Docker config file for the test.
+func writeTestConfig(t *testing.T, configPath string, config *configfile.ConfigFile) {
+	t.Helper()
+	configData, err := json.MarshalIndent(config, "", "\t")
+	if err != nil {
+		t.Fatalf("Unexpected error marshaling config: %v", err)
+	}
+	if err := os.WriteFile(configPath, configData, 0600); err != nil {
+		t.Fatalf("Unexpected error writing config file: %v", err)
+	}
+}
+
+// readTestConfig reads the Docker config file written by the test.
+func readTestConfig(t *testing.T, configPath string) *configfile.ConfigFile {
+	t.Helper()
+	configData, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+	var config configfile.ConfigFile
+	if err := json.Unmarshal(configData, &config); err != nil {
+		t.Fatalf("Failed to unmarshal config file: %v", err)
+	}
+	return &config
+}
+
+func TestRunSetupCommand_UnsetDefault(t *testing.T) {
+	testCases := []struct {
+		name           string
+		credsStore     string
+		args           []string
+		expectedStore  string
+		expectedOutput string
+	}{
+		{
+			name:           "env is unset",
+			credsStore:     "env",
+			args:           []string{"unset-default"},
+			expectedStore:  "",
+			expectedOutput: "Default credential store \"env\" successfully unset\n",
+		},
+		{
+			name:           "Not configured",
+			credsStore:     "",
+			args:           []string{"unset-default"},
+			expectedStore:  "",
+			expectedOutput: "Default credential store is not configured\n",
+		},
+		{
+			name:           "Other helper is kept",
+			credsStore:     "desktop",
+			args:           []string{"unset-default"},
+			expectedStore:  "desktop",
+			expectedOutput: "Default credential store is configured to use \"desktop\" credential helper, not \"env\"; use --force to unset it\n",
+		},
+		{
+			name:           "Other helper is unset with force",
+			credsStore:     "desktop",
+			args:           []string{"unset-default", "--force"},
+			expectedStore:  "",
+			expectedOutput: "Default credential store \"desktop\" successfully unset\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir := setupTestEnvironment(t)
+			configPath := filepath.Join(tempDir, "config.json")
+			writeTestConfig(t, configPath, &configfile.ConfigFile{
+				CredentialsStore:  tc.credsStore,
+				CredentialHelpers: map[string]string{"docker.io": "env"},
+			})
+
+			out := new(bytes.Buffer)
+			if err := RunSetupCommand(tc.args, out); err != nil {
+				t.Fatalf("RunSetupCommand() failed: %v", err)
+			}
+			if actual := out.String(); actual != tc.expectedOutput {
+				t.Errorf("Expected output %q, but got %q", tc.expectedOutput, actual)
+			}
+
+			config := readTestConfig(t, configPath)
+			if config.CredentialsStore != tc.expectedStore {
+				t.Errorf("Expected credsStore to be %q, got %q", tc.expectedStore, config.CredentialsStore)
+			}
+			if helper := config.CredentialHelpers["docker.io"]; helper != "env" {
+				t.Errorf("Expected credHelper for 'docker.io' to be 'env', got %q", helper)
+			}
+
+			// Repeating the command changes nothing
+			out.Reset()
+			if err := RunSetupCommand(tc.args, out); err != nil {
+				t.Fatalf("RunSetupCommand() failed: %v", err)
+			}
+			if config := readTestConfig(t, configPath); config.CredentialsStore != tc.expectedStore {
+				t.Errorf("Expected credsStore to remain %q, got %q", tc.expectedStore, config.CredentialsStore)
+			}
+		})
+	}
+
+	t.Run("Missing config file", func(t *testing.T) {
+		tempDir := setupTestEnvironment(t)
+		out := new(bytes.Buffer)
+		if err := RunSetupCommand([]string{"unset-default"}, out); err != nil {
+			t.Fatalf("RunSetupCommand() failed: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(tempDir, "config.json")); !os.IsNotExist(err) {
+			t.Errorf("Expected config file not to be created, but got %v", err)
+		}
+	})
+}
+
+func TestRunSetupCommand_Remove(t *testing.T) {
+	testCases := []struct {
+		name            string
+		args            []string
+		expectedHelpers map[string]string
+		expectedOutput  string
+	}{
+		{
+			name:            "Single registry",
+			args:            []string{"remove", "docker.io"},
+			expectedHelpers: map[string]string{"ghcr.io": "env", "gcr.io": "gcloud"},
+			expectedOutput:  "Registry \"docker.io\" successfully removed\n",
+		},
+		{
+			name:            "Several registries",
+			args:            []string{"remove", "docker.io", "ghcr.io"},
+			expectedHelpers: map[string]string{"gcr.io": "gcloud"},
+			expectedOutput:  "Registry \"docker.io\" successfully removed\nRegistry \"ghcr.io\" successfully removed\n",
+		},
+		{
+			name:            "Unconfigured registry",
+			args:            []string{"remove", "quay.io"},
+			expectedHelpers: map[string]string{"docker.io": "env", "ghcr.io": "env", "gcr.io": "gcloud"},
+			expectedOutput:  "Registry \"quay.io\" is not configured\n",
+		},
+		{
+			name:            "Other helper is kept",
+			args:            []string{"remove", "gcr.io", "docker.io"},
+			expectedHelpers: map[string]string{"ghcr.io": "env", "gcr.io": "gcloud"},
+			expectedOutput:  "Registry \"gcr.io\" is configured to use \"gcloud\" credential helper, not \"env\"; use --force to remove it\nRegistry \"docker.io\" successfully removed\n",
+		},
+		{
+			name:            "Other helper is removed with force",
+			args:            []string{"remove", "--force", "gcr.io"},
+			expectedHelpers: map[string]string{"docker.io": "env", "ghcr.io": "env"},
+			expectedOutput:  "Registry \"gcr.io\" successfully removed\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir := setupTestEnvironment(t)
+			configPath := filepath.Join(tempDir, "config.json")
+			writeTestConfig(t, configPath, &configfile.ConfigFile{
+				CredentialsStore:  "desktop",
+				CredentialHelpers: map[string]string{"docker.io": "env", "ghcr.io": "env", "gcr.io": "gcloud"},
+			})
+
+			out := new(bytes.Buffer)
+			if err := RunSetupCommand(tc.args, out); err != nil {
+				t.Fatalf("RunSetupCommand() failed: %v", err)
+			}
+			if actual := out.String(); actual != tc.expectedOutput {
+				t.Errorf("Expected output %q, but got %q", tc.expectedOutput, actual)
+			}
+
+			config := readTestConfig(t, configPath)
+			if !maps.Equal(config.CredentialHelpers, tc.expectedHelpers) {
+				t.Errorf("Expected credHelpers to be %v, got %v", tc.expectedHelpers, config.CredentialHelpers)
+			}
+			if config.CredentialsStore != "desktop" {
+				t.Errorf("Expected credsStore to be 'desktop', got %q", config.CredentialsStore)
+			}
+
+			// Repeating the command changes nothing
+			if err := RunSetupCommand(tc.args, new(bytes.Buffer)); err != nil {
+				t.Fatalf("RunSetupCommand() failed: %v", err)
+			}
+			if config := readTestConfig(t, configPath); !maps.Equal(config.CredentialHelpers, tc.expectedHelpers) {
+				t.Errorf("Expected credHelpers to remain %v, got %v", tc.expectedHelpers, config.CredentialHelpers)
+			}
+		})
+	}
+}
+
+func TestRunSetupCommand_PreservesConfig(t *testing.T) {
+	tempDir := setupTestEnvironment(t)
+	configPath := filepath.Join(tempDir, "config.json")
+	original := `{
+  "x-future-feature": {"enabled": true},
+  "auths": {
+    "registry.example.com": {}
+  },
+  "psFormat": "table {{.ID}}\t{{.Names}}",
+  "credHelpers": {
+    "gcr.io": "gcloud"
+  }
+}
+`
+
+	// The config file is a link, as installed by dotfile managers
+	targetPath := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(targetPath, []byte(original), 0640); err != nil {
+		t.Fatalf("Unexpected error writing config file: %v", err)
+	}
+	if err := os.Symlink(targetPath, configPath); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+
+	steps := []struct {
+		args     []string
+		expected string
+	}{
+		{
+			args: []string{"ghcr.io"},
+			expected: `{
+  "x-future-feature": {"enabled": true},
+  "auths": {
+    "registry.example.com": {}
+  },
+  "psFormat": "table {{.ID}}\t{{.Names}}",
+  "credHelpers": {
+    "gcr.io": "gcloud",
+    "ghcr.io": "env"
+  }
+}
+`,
+		},
+		{
+			args: []string{"default"},
+			expected: `{
+  "x-future-feature": {"enabled": true},
+  "auths": {
+    "registry.example.com": {}
+  },
+  "psFormat": "table {{.ID}}\t{{.Names}}",
+  "credHelpers": {
+    "gcr.io": "gcloud",
+    "ghcr.io": "env"
+  },
+  "credsStore": "env"
+}
+`,
+		},
+		{
+			args: []string{"remove", "ghcr.io"},
+			expected: `{
+  "x-future-feature": {"enabled": true},
+  "auths": {
+    "registry.example.com": {}
+  },
+  "psFormat": "table {{.ID}}\t{{.Names}}",
+  "credHelpers": {
+    "gcr.io": "gcloud"
+  },
+  "credsStore": "env"
+}
+`,
+		},
+		{
+			args:     []string{"unset-default"},
+			expected: original,
+		},
+	}
+
+	for _, step := range steps {
+		if err := RunSetupCommand(step.args, new(bytes.Buffer)); err != nil {
+			t.Fatalf("RunSetupCommand(%v) failed: %v", step.args, err)
+		}
+		actual, err := os.ReadFile(targetPath)
+		if err != nil {
+			t.Fatalf("Failed to read config file: %v", err)
+		}
+		if string(actual) != step.expected {
+			t.Errorf("RunSetupCommand(%v) config actual = (%s), expected (%s)", step.args, actual, step.expected)
+		}
+	}
+
+	if info, err := os.Lstat(configPath); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected %q to remain a symlink, got %v", configPath, err)
+	}
+	if info, err := os.Stat(targetPath); err != nil {
+		t.Errorf("Failed to stat config file: %v", err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0640 {
+		t.Errorf("Expected %q to keep mode 0640, got %v", targetPath, info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(filepath.Dir(targetPath))
+	if err != nil {
+		t.Fatalf("Failed to list config directory: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("Expected no temporary files to be left, got %q", entry.Name())
+		}
+	}
+	if backups, err := filepath.Glob(targetPath + ".*.bak"); err != nil || len(backups) != len(steps) {
+		t.Errorf("Expected %d backups alongside the linked file, got %v (%v)", len(steps), backups, err)
+	}
+}
+
+func TestRunSetupCommand_Restore(t *testing.T) {
+	tempDir := setupTestEnvironment(t)
+	configPath := filepath.Join(tempDir, "config.json")
+	original := "{\n  \"credHelpers\": {\"gcr.io\": \"gcloud\"}\n}\n"
+	if err := os.WriteFile(configPath, []byte(original), 0600); err != nil {
+		t.Fatalf("Unexpected error writing config file: %v", err)
+	}
+
+	for _, args := range [][]string{{"ghcr.io"}, {"default"}} {
+		if err := RunSetupCommand(args, new(bytes.Buffer)); err != nil {
+			t.Fatalf("RunSetupCommand(%v) failed: %v", args, err)
+		}
+	}
+	// Each restore steps back through one backup
+	for _, expected := range []func(*configfile.ConfigFile) bool{
+		func(config *configfile.ConfigFile) bool {
+			return config.CredentialsStore == "" && config.CredentialHelpers["ghcr.io"] == "env"
+		},
+		func(config *configfile.ConfigFile) bool {
+			return config.CredentialsStore == "" && maps.Equal(config.CredentialHelpers, map[string]string{"gcr.io": "gcloud"})
+		},
+	} {
+		out := new(bytes.Buffer)
+		if err := RunSetupCommand([]string{"restore"}, out); err != nil {
+			t.Fatalf("RunSetupCommand(restore) failed: %v", err)
+		}
+		if !strings.Contains(out.String(), "successfully restored from backup") {
+			t.Errorf("Expected restore message, got %q", out.String())
+		}
+		if config := readTestConfig(t, configPath); !expected(config) {
+			t.Errorf("RunSetupCommand(restore) config actual = (%v, %v)", config.CredentialsStore, config.CredentialHelpers)
+		}
+	}
+
+	if actual, err := os.ReadFile(configPath); err != nil || string(actual) != original {
+		t.Errorf("RunSetupCommand(restore) config actual = (%q), expected (%q)", actual, original)
+	}
+	if err := RunSetupCommand([]string{"restore"}, new(bytes.Buffer)); err == nil || !strings.Contains(err.Error(), "no backup") {
+		t.Errorf("Expected error to contain %q, but got %v", "no backup", err)
+	}
+}
+
+func TestRunSetupCommand_Concurrent(t *testing.T) {
+	tempDir := setupTestEnvironment(t)
+	configPath := filepath.Join(tempDir, "config.json")
+	writeTestConfig(t, configPath, &configfile.ConfigFile{CredentialHelpers: map[string]string{"gcr.io": "gcloud"}})
+
+	const workers = 50
+	expected := map[string]string{"gcr.io": "gcloud"}
+	errs := make(chan error, workers+1)
+	var wg sync.WaitGroup
+	for i := range workers {
+		registry := fmt.Sprintf("registry-%d.example.com", i)
+		expected[registry] = "env"
+		wg.Go(func() {
+			errs <- RunSetupCommand([]string{registry}, io.Discard)
+		})
+	}
+	wg.Go(func() {
+		errs <- RunSetupCommand([]string{"default"}, io.Discard)
+	})
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("RunSetupCommand() unexpected error: %v", err)
+		}
+	}
+
+	config := readTestConfig(t, configPath)
+	if config.CredentialsStore != "env" {
+		t.Errorf("Expected credsStore to be %q, got %q", "env", config.CredentialsStore)
+	}
+	if !maps.Equal(config.CredentialHelpers, expected) {
+		t.Errorf("Expected credHelpers to be %v, got %v", expected, config.CredentialHelpers)
+	}
+	if backups, err := filepath.Glob(configPath + ".*.bak"); err != nil || len(backups) != setupBackupLimit {
+		t.Errorf("Expected %d backups to be kept, got %d (%v)", setupBackupLimit, len(backups), err)
+	}
+}